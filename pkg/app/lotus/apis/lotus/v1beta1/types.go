@@ -20,38 +20,240 @@ type LotusSpec struct {
 	TTLSecondsAfterFinished  *int32 `json:"ttlSecondsAfterFinished"`
 	CheckIntervalSeconds     *int32 `json:"checkIntervalSeconds"`
 	CheckInitialDelaySeconds *int32 `json:"checkInitialDelaySeconds"`
+	// InterimReportIntervalSeconds makes the monitor send a partial result
+	// to the receivers opted in to interim reports while the test is running.
+	InterimReportIntervalSeconds *int32 `json:"interimReportIntervalSeconds"`
 
-	Preparer *LotusSpecPreparer `json:"preparer"`
-	Worker   *LotusSpecWorker   `json:"worker"`
-	Cleaner  *LotusSpecCleaner  `json:"cleaner"`
-	Checks   []LotusCheck       `json:"checks"`
+	// RetentionPolicy decides whether the per-test Prometheus and monitor
+	// resources are deleted once the test has finished and been reported.
+	// The Lotus object itself is always kept. Defaults to keep.
+	RetentionPolicy LotusRetentionPolicy `json:"retentionPolicy"`
+	// RetentionGracePeriodSeconds is the duration to wait after the test has
+	// finished before deleting the resources. Defaults to 300.
+	RetentionGracePeriodSeconds *int32 `json:"retentionGracePeriodSeconds"`
+	// Priority orders the queued Lotuses when the concurrency limits
+	// are reached. Lotuses with higher priority are started first.
+	Priority int32 `json:"priority"`
+
+	Common    *LotusSpecCommon   `json:"common"`
+	Preflight *LotusPreflight    `json:"preflight"`
+	Preparer  *LotusSpecPreparer `json:"preparer"`
+	Worker    *LotusSpecWorker   `json:"worker"`
+	Cleaner   *LotusSpecCleaner  `json:"cleaner"`
+	Checks    []LotusCheck       `json:"checks"`
+	// Assertions are evaluated once over the whole run after the test has finished.
+	Assertions []LotusAssertion `json:"assertions"`
+	// StopConditions end the Running phase before runTime expires.
+	StopConditions *LotusStopConditions `json:"stopConditions"`
+	// FailureCriteria replaces the codes counted as failures by the global config.
+	FailureCriteria *LotusFailureCriteria `json:"failureCriteria"`
+	// Summary adds user-defined metrics to the metrics summary of the result.
+	Summary *LotusSummary `json:"summary"`
+}
+
+type LotusSummary struct {
+	Metrics []LotusSummaryMetric `json:"metrics"`
+}
+
+// LotusSummaryMetric is a named query evaluated at the end of the test window.
+// $__range in the expression is replaced by the duration of the test window.
+type LotusSummaryMetric struct {
+	Name string `json:"name"`
+	Expr string `json:"expr"`
+	// The labels whose values identify the rows of a grouped metric.
+	// The expression must return a single value if it is empty.
+	GroupBy []string `json:"groupBy"`
+}
+
+// LotusFailureCriteria decides which completed requests are counted as failures.
+// The codes are regular expressions of HTTP status codes or gRPC code names.
+type LotusFailureCriteria struct {
+	HTTPStatusCodes []string `json:"httpStatusCodes"`
+	GRPCCodes       []string `json:"grpcCodes"`
+	// Overrides take precedence over the global ones. The first matching override is used.
+	Overrides []LotusFailureOverride `json:"overrides"`
+}
+
+// LotusFailureOverride replaces the failure codes for the HTTP routes
+// or gRPC methods matching the patterns, which may contain "*" wildcards.
+type LotusFailureOverride struct {
+	HTTPRoute       string   `json:"httpRoute"`
+	HTTPStatusCodes []string `json:"httpStatusCodes"`
+	GRPCMethod      string   `json:"grpcMethod"`
+	GRPCCodes       []string `json:"grpcCodes"`
+}
+
+// LotusStopConditions end the test successfully as soon as any of them is met.
+type LotusStopConditions struct {
+	// Stop when every worker container has exited successfully.
+	WorkersTerminated bool `json:"workersTerminated"`
+	// Stop when all started virtual users have either succeeded or failed.
+	VirtualUsersFinished bool `json:"virtualUsersFinished"`
+	// Stop when any of the expressions returns a sample.
+	Conditions []LotusStopCondition `json:"conditions"`
+}
+
+type LotusStopCondition struct {
+	Name       string `json:"name"`
+	Expr       string `json:"expr"`
+	DataSource string `json:"dataSource"`
+}
+
+// LotusPreflight specifies the checks which must pass before the test is started.
+// The Lotus fails without running anything if any of them does not pass.
+type LotusPreflight struct {
+	HTTPProbes []LotusHTTPProbe          `json:"httpProbes"`
+	GRPCProbes []LotusGRPCProbe          `json:"grpcProbes"`
+	Conditions []LotusPreflightCondition `json:"conditions"`
+	// CheckQuota verifies that the resource quotas of the namespace
+	// have enough room for all the worker replicas.
+	CheckQuota     bool   `json:"checkQuota"`
+	TimeoutSeconds *int32 `json:"timeoutSeconds"`
+}
+
+// LotusHTTPProbe passes when a GET request to the URL returns the expected
+// status code, or any 2xx status code if it is not specified.
+type LotusHTTPProbe struct {
+	URL            string `json:"url"`
+	ExpectedStatus int32  `json:"expectedStatus"`
+}
+
+// LotusGRPCProbe passes when the target reports SERVING
+// for the standard grpc.health.v1.Health/Check request.
+type LotusGRPCProbe struct {
+	Address string `json:"address"`
+	Service string `json:"service"`
+}
+
+// LotusPreflightCondition passes when the query returns at least one sample,
+// e.g. "sum(rate(errors[5m])) / sum(rate(requests[5m])) < 0.01".
+type LotusPreflightCondition struct {
+	Name       string `json:"name"`
+	Expr       string `json:"expr"`
+	DataSource string `json:"dataSource"`
+}
+
+// LotusSpecCommon is merged into all containers of the preparer, workers and cleaner.
+// The values specified by each container take precedence.
+type LotusSpecCommon struct {
+	Env          []corev1.EnvVar        `json:"env"`
+	EnvFrom      []corev1.EnvFromSource `json:"envFrom"`
+	Volumes      []corev1.Volume        `json:"volumes"`
+	VolumeMounts []corev1.VolumeMount   `json:"volumeMounts"`
 }
 
 type LotusSpecWorker struct {
+	Mode        LotusWorkerMode    `json:"mode"`
 	RunTime     string             `json:"runTime"`
 	Replicas    *int32             `json:"replicas"`
 	MetricsPort *int32             `json:"metricsPort"`
+	TemplateRef *LotusTemplateRef  `json:"templateRef"`
 	Containers  []corev1.Container `json:"containers"`
 	Volumes     []corev1.Volume    `json:"volumes"`
 }
 
+// LotusWorkerMode specifies how the worker replicas are run.
+// In Deployment mode the workers are restarted until runTime expires,
+// while in Job mode each replica runs to completion as an indexed shard.
+type LotusWorkerMode string
+
+const (
+	LotusWorkerDeployment LotusWorkerMode = "Deployment"
+	LotusWorkerJob                        = "Job"
+)
+
+type LotusRetentionPolicy string
+
+const (
+	LotusRetentionKeep            LotusRetentionPolicy = "keep"
+	LotusRetentionDeleteOnSuccess                      = "delete-on-success"
+	LotusRetentionDeleteAlways                         = "delete-always"
+)
+
+// LotusTemplateRef references an existing pod spec in the namespace of the Lotus.
+// It is resolved by the controller when the Lotus is created: the containers
+// and volumes of the referenced pod spec are kept in status.resolvedTemplates,
+// and used before the ones specified inline.
+type LotusTemplateRef struct {
+	Kind LotusTemplateKind `json:"kind"`
+	Name string            `json:"name"`
+	// Key is the key of the ConfigMap containing the pod spec in YAML or JSON.
+	// Defaults to "podSpec.yaml".
+	Key string `json:"key"`
+}
+
+type LotusTemplateKind string
+
+const (
+	LotusTemplatePodTemplate LotusTemplateKind = "PodTemplate"
+	LotusTemplateConfigMap                     = "ConfigMap"
+)
+
 type LotusSpecPreparer struct {
-	Containers []corev1.Container `json:"containers"`
-	Volumes    []corev1.Volume    `json:"volumes"`
+	TemplateRef *LotusTemplateRef  `json:"templateRef"`
+	Containers  []corev1.Container `json:"containers"`
+	Volumes     []corev1.Volume    `json:"volumes"`
 }
 
 type LotusSpecCleaner struct {
-	Containers []corev1.Container `json:"containers"`
-	Volumes    []corev1.Volume    `json:"volumes"`
+	TemplateRef *LotusTemplateRef  `json:"templateRef"`
+	Containers  []corev1.Container `json:"containers"`
+	Volumes     []corev1.Volume    `json:"volumes"`
 }
 
+// LotusCheck fires when Expr returns any sample for the duration of For.
+// Instead of Expr, a threshold of a built-in metric can be specified
+// by Metric, Op and Value, e.g. http.latency < 300ms.
 type LotusCheck struct {
-	Name       string `json:"name"`
-	Expr       string `json:"expr"`
-	For        string `json:"for"`
-	DataSource string `json:"dataSource"`
+	Name       string             `json:"name"`
+	Expr       string             `json:"expr"`
+	For        string             `json:"for"`
+	DataSource string             `json:"dataSource"`
+	Severity   LotusCheckSeverity `json:"severity"`
+
+	Metric   string  `json:"metric,omitempty"`
+	Quantile float64 `json:"quantile,omitempty"`
+	Host     string  `json:"host,omitempty"`
+	Route    string  `json:"route,omitempty"`
+	Method   string  `json:"method,omitempty"`
+	Op       string  `json:"op,omitempty"`
+	Value    string  `json:"value,omitempty"`
+}
+
+// LotusAssertion compares the value of a scalar expression with a threshold.
+// $__range in the expression is replaced by the duration of the test run,
+// and the expression is evaluated at the end of the run.
+type LotusAssertion struct {
+	Name       string                 `json:"name"`
+	Expr       string                 `json:"expr"`
+	Operator   LotusAssertionOperator `json:"operator"`
+	Threshold  float64                `json:"threshold"`
+	DataSource string                 `json:"dataSource"`
 }
 
+type LotusAssertionOperator string
+
+const (
+	LotusAssertionLess           LotusAssertionOperator = "<"
+	LotusAssertionLessOrEqual                           = "<="
+	LotusAssertionGreater                               = ">"
+	LotusAssertionGreaterOrEqual                        = ">="
+	LotusAssertionEqual                                 = "=="
+	LotusAssertionNotEqual                              = "!="
+)
+
+// LotusCheckSeverity specifies what happens to the test when a check fires.
+type LotusCheckSeverity string
+
+const (
+	// Abort the test right away. This is the default.
+	LotusCheckAbort LotusCheckSeverity = "abort"
+	// Let the test run its full duration but mark it as failed.
+	LotusCheckFail = "fail"
+	// Only report the check.
+	LotusCheckWarn = "warn"
+)
+
 type LotusPhase string
 
 const (
@@ -73,6 +275,55 @@ type LotusStatus struct {
 	CleanerStartTime       *metav1.Time `json:"cleanerStartTime"`
 	CleanerCompletionTime  *metav1.Time `json:"cleanerCompletionTime"`
 	Phase                  LotusPhase   `json:"phase"`
+	// Reason is a human readable explanation of why the Lotus failed
+	// before running the test, e.g. because of a preflight check.
+	Reason string `json:"reason,omitempty"`
+	// ResourcesDeletionTime is the time when the per-test resources
+	// were deleted according to the retention policy.
+	ResourcesDeletionTime *metav1.Time `json:"resourcesDeletionTime,omitempty"`
+	// QueuePosition is the 1-based position of a Pending Lotus in the queue
+	// waiting for the concurrency limits. It is unset once the Lotus started.
+	QueuePosition *int32 `json:"queuePosition,omitempty"`
+
+	// Annotations records the notable events happened while running the test,
+	// such as changes applied to the workers. They are also exported
+	// to the metrics timeline of the test.
+	Annotations []LotusAnnotation `json:"annotations,omitempty"`
+	// Progress is the live progress of the test published from the monitor
+	// while the Lotus is in the Running phase.
+	Progress *LotusProgress `json:"progress,omitempty"`
+	// ResolvedTemplates are the pod specs referenced by the templateRef of the stages,
+	// resolved when the Lotus is created. The spec itself is left untouched.
+	ResolvedTemplates *LotusResolvedTemplates `json:"resolvedTemplates,omitempty"`
+}
+
+type LotusResolvedTemplates struct {
+	Preparer *LotusPodSpec `json:"preparer,omitempty"`
+	Worker   *LotusPodSpec `json:"worker,omitempty"`
+	Cleaner  *LotusPodSpec `json:"cleaner,omitempty"`
+}
+
+// LotusPodSpec is the part of a referenced pod spec used by a stage.
+type LotusPodSpec struct {
+	Containers []corev1.Container `json:"containers"`
+	Volumes    []corev1.Volume    `json:"volumes,omitempty"`
+}
+
+type LotusProgress struct {
+	// UpdateTime is when the monitor observed the metrics.
+	UpdateTime       metav1.Time `json:"updateTime"`
+	ElapsedSeconds   int64       `json:"elapsedSeconds"`
+	RemainingSeconds int64       `json:"remainingSeconds"`
+	// RPS is the number of HTTP requests and gRPC calls per second over the last minute.
+	RPS string `json:"rps"`
+	// ErrorPercentage is the percentage of the failed requests and calls over the last minute.
+	ErrorPercentage string   `json:"errorPercentage"`
+	FiringChecks    []string `json:"firingChecks,omitempty"`
+}
+
+type LotusAnnotation struct {
+	Time metav1.Time `json:"time"`
+	Text string      `json:"text"`
 }
 
 // +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
@@ -83,3 +334,62 @@ type LotusList struct {
 
 	Items []Lotus `json:"items"`
 }
+
+// +genclient
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+// LotusTrigger creates a Lotus from its template whenever a watched
+// Deployment or StatefulSet has completed the rollout of a new image.
+type LotusTrigger struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   LotusTriggerSpec   `json:"spec"`
+	Status LotusTriggerStatus `json:"status"`
+}
+
+type LotusTriggerSpec struct {
+	Target   LotusTriggerTarget `json:"target"`
+	Template LotusTemplate      `json:"template"`
+}
+
+// LotusTriggerTarget selects the workloads whose rollouts trigger the test.
+type LotusTriggerTarget struct {
+	Kind      LotusTriggerTargetKind `json:"kind"`
+	Namespace string                 `json:"namespace"`
+	Selector  *metav1.LabelSelector  `json:"selector"`
+	// Container is the name of the container whose image is watched.
+	// The images of all containers are watched if it is empty.
+	Container string `json:"container"`
+}
+
+type LotusTriggerTargetKind string
+
+const (
+	LotusTriggerDeployment  LotusTriggerTargetKind = "Deployment"
+	LotusTriggerStatefulSet                        = "StatefulSet"
+)
+
+// LotusTemplate is the template of the Lotuses created by a trigger.
+type LotusTemplate struct {
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec LotusSpec `json:"spec"`
+}
+
+type LotusTriggerStatus struct {
+	// ObservedImages is the last rolled out image of each watched workload
+	// keyed by "<kind>/<name>".
+	ObservedImages    map[string]string `json:"observedImages,omitempty"`
+	LastTriggeredTime *metav1.Time      `json:"lastTriggeredTime,omitempty"`
+	LastLotusName     string            `json:"lastLotusName,omitempty"`
+}
+
+// +k8s:deepcopy-gen:interfaces=k8s.io/apimachinery/pkg/runtime.Object
+
+type LotusTriggerList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata"`
+
+	Items []LotusTrigger `json:"items"`
+}
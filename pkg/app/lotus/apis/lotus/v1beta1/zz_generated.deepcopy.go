@@ -26,6 +26,7 @@ package v1beta1
 
 import (
 	v1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	runtime "k8s.io/apimachinery/pkg/runtime"
 )
 
@@ -57,6 +58,39 @@ func (in *Lotus) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LotusAnnotation) DeepCopyInto(out *LotusAnnotation) {
+	*out = *in
+	in.Time.DeepCopyInto(&out.Time)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LotusAnnotation.
+func (in *LotusAnnotation) DeepCopy() *LotusAnnotation {
+	if in == nil {
+		return nil
+	}
+	out := new(LotusAnnotation)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LotusAssertion) DeepCopyInto(out *LotusAssertion) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LotusAssertion.
+func (in *LotusAssertion) DeepCopy() *LotusAssertion {
+	if in == nil {
+		return nil
+	}
+	out := new(LotusAssertion)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LotusCheck) DeepCopyInto(out *LotusCheck) {
 	*out = *in
@@ -73,6 +107,97 @@ func (in *LotusCheck) DeepCopy() *LotusCheck {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LotusFailureCriteria) DeepCopyInto(out *LotusFailureCriteria) {
+	*out = *in
+	if in.HTTPStatusCodes != nil {
+		in, out := &in.HTTPStatusCodes, &out.HTTPStatusCodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.GRPCCodes != nil {
+		in, out := &in.GRPCCodes, &out.GRPCCodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.Overrides != nil {
+		in, out := &in.Overrides, &out.Overrides
+		*out = make([]LotusFailureOverride, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LotusFailureCriteria.
+func (in *LotusFailureCriteria) DeepCopy() *LotusFailureCriteria {
+	if in == nil {
+		return nil
+	}
+	out := new(LotusFailureCriteria)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LotusFailureOverride) DeepCopyInto(out *LotusFailureOverride) {
+	*out = *in
+	if in.HTTPStatusCodes != nil {
+		in, out := &in.HTTPStatusCodes, &out.HTTPStatusCodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	if in.GRPCCodes != nil {
+		in, out := &in.GRPCCodes, &out.GRPCCodes
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LotusFailureOverride.
+func (in *LotusFailureOverride) DeepCopy() *LotusFailureOverride {
+	if in == nil {
+		return nil
+	}
+	out := new(LotusFailureOverride)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LotusGRPCProbe) DeepCopyInto(out *LotusGRPCProbe) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LotusGRPCProbe.
+func (in *LotusGRPCProbe) DeepCopy() *LotusGRPCProbe {
+	if in == nil {
+		return nil
+	}
+	out := new(LotusGRPCProbe)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LotusHTTPProbe) DeepCopyInto(out *LotusHTTPProbe) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LotusHTTPProbe.
+func (in *LotusHTTPProbe) DeepCopy() *LotusHTTPProbe {
+	if in == nil {
+		return nil
+	}
+	out := new(LotusHTTPProbe)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LotusList) DeepCopyInto(out *LotusList) {
 	*out = *in
@@ -106,6 +231,141 @@ func (in *LotusList) DeepCopyObject() runtime.Object {
 	return nil
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LotusPodSpec) DeepCopyInto(out *LotusPodSpec) {
+	*out = *in
+	if in.Containers != nil {
+		in, out := &in.Containers, &out.Containers
+		*out = make([]v1.Container, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = make([]v1.Volume, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LotusPodSpec.
+func (in *LotusPodSpec) DeepCopy() *LotusPodSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(LotusPodSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LotusPreflight) DeepCopyInto(out *LotusPreflight) {
+	*out = *in
+	if in.HTTPProbes != nil {
+		in, out := &in.HTTPProbes, &out.HTTPProbes
+		*out = make([]LotusHTTPProbe, len(*in))
+		copy(*out, *in)
+	}
+	if in.GRPCProbes != nil {
+		in, out := &in.GRPCProbes, &out.GRPCProbes
+		*out = make([]LotusGRPCProbe, len(*in))
+		copy(*out, *in)
+	}
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]LotusPreflightCondition, len(*in))
+		copy(*out, *in)
+	}
+	if in.TimeoutSeconds != nil {
+		in, out := &in.TimeoutSeconds, &out.TimeoutSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LotusPreflight.
+func (in *LotusPreflight) DeepCopy() *LotusPreflight {
+	if in == nil {
+		return nil
+	}
+	out := new(LotusPreflight)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LotusPreflightCondition) DeepCopyInto(out *LotusPreflightCondition) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LotusPreflightCondition.
+func (in *LotusPreflightCondition) DeepCopy() *LotusPreflightCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(LotusPreflightCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LotusProgress) DeepCopyInto(out *LotusProgress) {
+	*out = *in
+	in.UpdateTime.DeepCopyInto(&out.UpdateTime)
+	if in.FiringChecks != nil {
+		in, out := &in.FiringChecks, &out.FiringChecks
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LotusProgress.
+func (in *LotusProgress) DeepCopy() *LotusProgress {
+	if in == nil {
+		return nil
+	}
+	out := new(LotusProgress)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LotusResolvedTemplates) DeepCopyInto(out *LotusResolvedTemplates) {
+	*out = *in
+	if in.Preparer != nil {
+		in, out := &in.Preparer, &out.Preparer
+		*out = new(LotusPodSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Worker != nil {
+		in, out := &in.Worker, &out.Worker
+		*out = new(LotusPodSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Cleaner != nil {
+		in, out := &in.Cleaner, &out.Cleaner
+		*out = new(LotusPodSpec)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LotusResolvedTemplates.
+func (in *LotusResolvedTemplates) DeepCopy() *LotusResolvedTemplates {
+	if in == nil {
+		return nil
+	}
+	out := new(LotusResolvedTemplates)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LotusSpec) DeepCopyInto(out *LotusSpec) {
 	*out = *in
@@ -124,6 +384,26 @@ func (in *LotusSpec) DeepCopyInto(out *LotusSpec) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.InterimReportIntervalSeconds != nil {
+		in, out := &in.InterimReportIntervalSeconds, &out.InterimReportIntervalSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.RetentionGracePeriodSeconds != nil {
+		in, out := &in.RetentionGracePeriodSeconds, &out.RetentionGracePeriodSeconds
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Common != nil {
+		in, out := &in.Common, &out.Common
+		*out = new(LotusSpecCommon)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Preflight != nil {
+		in, out := &in.Preflight, &out.Preflight
+		*out = new(LotusPreflight)
+		(*in).DeepCopyInto(*out)
+	}
 	if in.Preparer != nil {
 		in, out := &in.Preparer, &out.Preparer
 		*out = new(LotusSpecPreparer)
@@ -144,6 +424,26 @@ func (in *LotusSpec) DeepCopyInto(out *LotusSpec) {
 		*out = make([]LotusCheck, len(*in))
 		copy(*out, *in)
 	}
+	if in.Assertions != nil {
+		in, out := &in.Assertions, &out.Assertions
+		*out = make([]LotusAssertion, len(*in))
+		copy(*out, *in)
+	}
+	if in.StopConditions != nil {
+		in, out := &in.StopConditions, &out.StopConditions
+		*out = new(LotusStopConditions)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.FailureCriteria != nil {
+		in, out := &in.FailureCriteria, &out.FailureCriteria
+		*out = new(LotusFailureCriteria)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.Summary != nil {
+		in, out := &in.Summary, &out.Summary
+		*out = new(LotusSummary)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -160,6 +460,11 @@ func (in *LotusSpec) DeepCopy() *LotusSpec {
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LotusSpecCleaner) DeepCopyInto(out *LotusSpecCleaner) {
 	*out = *in
+	if in.TemplateRef != nil {
+		in, out := &in.TemplateRef, &out.TemplateRef
+		*out = new(LotusTemplateRef)
+		**out = **in
+	}
 	if in.Containers != nil {
 		in, out := &in.Containers, &out.Containers
 		*out = make([]v1.Container, len(*in))
@@ -187,9 +492,58 @@ func (in *LotusSpecCleaner) DeepCopy() *LotusSpecCleaner {
 	return out
 }
 
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LotusSpecCommon) DeepCopyInto(out *LotusSpecCommon) {
+	*out = *in
+	if in.Env != nil {
+		in, out := &in.Env, &out.Env
+		*out = make([]v1.EnvVar, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.EnvFrom != nil {
+		in, out := &in.EnvFrom, &out.EnvFrom
+		*out = make([]v1.EnvFromSource, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Volumes != nil {
+		in, out := &in.Volumes, &out.Volumes
+		*out = make([]v1.Volume, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.VolumeMounts != nil {
+		in, out := &in.VolumeMounts, &out.VolumeMounts
+		*out = make([]v1.VolumeMount, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LotusSpecCommon.
+func (in *LotusSpecCommon) DeepCopy() *LotusSpecCommon {
+	if in == nil {
+		return nil
+	}
+	out := new(LotusSpecCommon)
+	in.DeepCopyInto(out)
+	return out
+}
+
 // DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
 func (in *LotusSpecPreparer) DeepCopyInto(out *LotusSpecPreparer) {
 	*out = *in
+	if in.TemplateRef != nil {
+		in, out := &in.TemplateRef, &out.TemplateRef
+		*out = new(LotusTemplateRef)
+		**out = **in
+	}
 	if in.Containers != nil {
 		in, out := &in.Containers, &out.Containers
 		*out = make([]v1.Container, len(*in))
@@ -230,6 +584,11 @@ func (in *LotusSpecWorker) DeepCopyInto(out *LotusSpecWorker) {
 		*out = new(int32)
 		**out = **in
 	}
+	if in.TemplateRef != nil {
+		in, out := &in.TemplateRef, &out.TemplateRef
+		*out = new(LotusTemplateRef)
+		**out = **in
+	}
 	if in.Containers != nil {
 		in, out := &in.Containers, &out.Containers
 		*out = make([]v1.Container, len(*in))
@@ -284,6 +643,32 @@ func (in *LotusStatus) DeepCopyInto(out *LotusStatus) {
 		in, out := &in.CleanerCompletionTime, &out.CleanerCompletionTime
 		*out = (*in).DeepCopy()
 	}
+	if in.ResourcesDeletionTime != nil {
+		in, out := &in.ResourcesDeletionTime, &out.ResourcesDeletionTime
+		*out = (*in).DeepCopy()
+	}
+	if in.QueuePosition != nil {
+		in, out := &in.QueuePosition, &out.QueuePosition
+		*out = new(int32)
+		**out = **in
+	}
+	if in.Annotations != nil {
+		in, out := &in.Annotations, &out.Annotations
+		*out = make([]LotusAnnotation, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	if in.Progress != nil {
+		in, out := &in.Progress, &out.Progress
+		*out = new(LotusProgress)
+		(*in).DeepCopyInto(*out)
+	}
+	if in.ResolvedTemplates != nil {
+		in, out := &in.ResolvedTemplates, &out.ResolvedTemplates
+		*out = new(LotusResolvedTemplates)
+		(*in).DeepCopyInto(*out)
+	}
 	return
 }
 
@@ -296,3 +681,245 @@ func (in *LotusStatus) DeepCopy() *LotusStatus {
 	in.DeepCopyInto(out)
 	return out
 }
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LotusStopCondition) DeepCopyInto(out *LotusStopCondition) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LotusStopCondition.
+func (in *LotusStopCondition) DeepCopy() *LotusStopCondition {
+	if in == nil {
+		return nil
+	}
+	out := new(LotusStopCondition)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LotusStopConditions) DeepCopyInto(out *LotusStopConditions) {
+	*out = *in
+	if in.Conditions != nil {
+		in, out := &in.Conditions, &out.Conditions
+		*out = make([]LotusStopCondition, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LotusStopConditions.
+func (in *LotusStopConditions) DeepCopy() *LotusStopConditions {
+	if in == nil {
+		return nil
+	}
+	out := new(LotusStopConditions)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LotusSummary) DeepCopyInto(out *LotusSummary) {
+	*out = *in
+	if in.Metrics != nil {
+		in, out := &in.Metrics, &out.Metrics
+		*out = make([]LotusSummaryMetric, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LotusSummary.
+func (in *LotusSummary) DeepCopy() *LotusSummary {
+	if in == nil {
+		return nil
+	}
+	out := new(LotusSummary)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LotusSummaryMetric) DeepCopyInto(out *LotusSummaryMetric) {
+	*out = *in
+	if in.GroupBy != nil {
+		in, out := &in.GroupBy, &out.GroupBy
+		*out = make([]string, len(*in))
+		copy(*out, *in)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LotusSummaryMetric.
+func (in *LotusSummaryMetric) DeepCopy() *LotusSummaryMetric {
+	if in == nil {
+		return nil
+	}
+	out := new(LotusSummaryMetric)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LotusTemplate) DeepCopyInto(out *LotusTemplate) {
+	*out = *in
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LotusTemplate.
+func (in *LotusTemplate) DeepCopy() *LotusTemplate {
+	if in == nil {
+		return nil
+	}
+	out := new(LotusTemplate)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LotusTemplateRef) DeepCopyInto(out *LotusTemplateRef) {
+	*out = *in
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LotusTemplateRef.
+func (in *LotusTemplateRef) DeepCopy() *LotusTemplateRef {
+	if in == nil {
+		return nil
+	}
+	out := new(LotusTemplateRef)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LotusTrigger) DeepCopyInto(out *LotusTrigger) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ObjectMeta.DeepCopyInto(&out.ObjectMeta)
+	in.Spec.DeepCopyInto(&out.Spec)
+	in.Status.DeepCopyInto(&out.Status)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LotusTrigger.
+func (in *LotusTrigger) DeepCopy() *LotusTrigger {
+	if in == nil {
+		return nil
+	}
+	out := new(LotusTrigger)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *LotusTrigger) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LotusTriggerList) DeepCopyInto(out *LotusTriggerList) {
+	*out = *in
+	out.TypeMeta = in.TypeMeta
+	in.ListMeta.DeepCopyInto(&out.ListMeta)
+	if in.Items != nil {
+		in, out := &in.Items, &out.Items
+		*out = make([]LotusTrigger, len(*in))
+		for i := range *in {
+			(*in)[i].DeepCopyInto(&(*out)[i])
+		}
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LotusTriggerList.
+func (in *LotusTriggerList) DeepCopy() *LotusTriggerList {
+	if in == nil {
+		return nil
+	}
+	out := new(LotusTriggerList)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyObject is an autogenerated deepcopy function, copying the receiver, creating a new runtime.Object.
+func (in *LotusTriggerList) DeepCopyObject() runtime.Object {
+	if c := in.DeepCopy(); c != nil {
+		return c
+	}
+	return nil
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LotusTriggerSpec) DeepCopyInto(out *LotusTriggerSpec) {
+	*out = *in
+	in.Target.DeepCopyInto(&out.Target)
+	in.Template.DeepCopyInto(&out.Template)
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LotusTriggerSpec.
+func (in *LotusTriggerSpec) DeepCopy() *LotusTriggerSpec {
+	if in == nil {
+		return nil
+	}
+	out := new(LotusTriggerSpec)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LotusTriggerStatus) DeepCopyInto(out *LotusTriggerStatus) {
+	*out = *in
+	if in.ObservedImages != nil {
+		in, out := &in.ObservedImages, &out.ObservedImages
+		*out = make(map[string]string, len(*in))
+		for key, val := range *in {
+			(*out)[key] = val
+		}
+	}
+	if in.LastTriggeredTime != nil {
+		in, out := &in.LastTriggeredTime, &out.LastTriggeredTime
+		*out = (*in).DeepCopy()
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LotusTriggerStatus.
+func (in *LotusTriggerStatus) DeepCopy() *LotusTriggerStatus {
+	if in == nil {
+		return nil
+	}
+	out := new(LotusTriggerStatus)
+	in.DeepCopyInto(out)
+	return out
+}
+
+// DeepCopyInto is an autogenerated deepcopy function, copying the receiver, writing into out. in must be non-nil.
+func (in *LotusTriggerTarget) DeepCopyInto(out *LotusTriggerTarget) {
+	*out = *in
+	if in.Selector != nil {
+		in, out := &in.Selector, &out.Selector
+		*out = new(metav1.LabelSelector)
+		(*in).DeepCopyInto(*out)
+	}
+	return
+}
+
+// DeepCopy is an autogenerated deepcopy function, copying the receiver, creating a new LotusTriggerTarget.
+func (in *LotusTriggerTarget) DeepCopy() *LotusTriggerTarget {
+	if in == nil {
+		return nil
+	}
+	out := new(LotusTriggerTarget)
+	in.DeepCopyInto(out)
+	return out
+}
@@ -36,6 +36,10 @@ func (c *FakeLotusV1beta1) Lotuses(namespace string) v1beta1.LotusInterface {
 	return &FakeLotuses{c, namespace}
 }
 
+func (c *FakeLotusV1beta1) LotusTriggers(namespace string) v1beta1.LotusTriggerInterface {
+	return &FakeLotusTriggers{c, namespace}
+}
+
 // RESTClient returns a RESTClient that is used to communicate
 // with API server by this client implementation.
 func (c *FakeLotusV1beta1) RESTClient() rest.Interface {
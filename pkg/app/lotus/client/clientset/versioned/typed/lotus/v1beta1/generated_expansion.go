@@ -23,3 +23,5 @@
 package v1beta1
 
 type LotusExpansion interface{}
+
+type LotusTriggerExpansion interface{}
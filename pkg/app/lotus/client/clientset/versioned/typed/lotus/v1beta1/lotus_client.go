@@ -31,6 +31,7 @@ import (
 type LotusV1beta1Interface interface {
 	RESTClient() rest.Interface
 	LotusesGetter
+	LotusTriggersGetter
 }
 
 // LotusV1beta1Client is used to interact with features provided by the lotus.lotusload.com group.
@@ -42,6 +43,10 @@ func (c *LotusV1beta1Client) Lotuses(namespace string) LotusInterface {
 	return newLotuses(c, namespace)
 }
 
+func (c *LotusV1beta1Client) LotusTriggers(namespace string) LotusTriggerInterface {
+	return newLotusTriggers(c, namespace)
+}
+
 // NewForConfig creates a new LotusV1beta1Client for the given config.
 func NewForConfig(c *rest.Config) (*LotusV1beta1Client, error) {
 	config := *c
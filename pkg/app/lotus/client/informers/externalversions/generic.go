@@ -59,6 +59,8 @@ func (f *sharedInformerFactory) ForResource(resource schema.GroupVersionResource
 	// Group=lotus.lotusload.com, Version=v1beta1
 	case v1beta1.SchemeGroupVersion.WithResource("lotuses"):
 		return &genericInformer{resource: resource.GroupResource(), informer: f.Lotus().V1beta1().Lotuses().Informer()}, nil
+	case v1beta1.SchemeGroupVersion.WithResource("lotustriggers"):
+		return &genericInformer{resource: resource.GroupResource(), informer: f.Lotus().V1beta1().LotusTriggers().Informer()}, nil
 
 	}
 
@@ -30,6 +30,8 @@ import (
 type Interface interface {
 	// Lotuses returns a LotusInformer.
 	Lotuses() LotusInformer
+	// LotusTriggers returns a LotusTriggerInformer.
+	LotusTriggers() LotusTriggerInformer
 }
 
 type version struct {
@@ -47,3 +49,8 @@ func New(f internalinterfaces.SharedInformerFactory, namespace string, tweakList
 func (v *version) Lotuses() LotusInformer {
 	return &lotusInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
 }
+
+// LotusTriggers returns a LotusTriggerInformer.
+func (v *version) LotusTriggers() LotusTriggerInformer {
+	return &lotusTriggerInformer{factory: v.factory, namespace: v.namespace, tweakListOptions: v.tweakListOptions}
+}
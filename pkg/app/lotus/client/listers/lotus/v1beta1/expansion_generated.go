@@ -29,3 +29,11 @@ type LotusListerExpansion interface{}
 // LotusNamespaceListerExpansion allows custom methods to be added to
 // LotusNamespaceLister.
 type LotusNamespaceListerExpansion interface{}
+
+// LotusTriggerListerExpansion allows custom methods to be added to
+// LotusTriggerLister.
+type LotusTriggerListerExpansion interface{}
+
+// LotusTriggerNamespaceListerExpansion allows custom methods to be added to
+// LotusTriggerNamespaceLister.
+type LotusTriggerNamespaceListerExpansion interface{}
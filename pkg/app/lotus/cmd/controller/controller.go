@@ -34,7 +34,9 @@ import (
 	clientset "github.com/lotusload/lotus/pkg/app/lotus/client/clientset/versioned"
 	informers "github.com/lotusload/lotus/pkg/app/lotus/client/informers/externalversions"
 	lotus "github.com/lotusload/lotus/pkg/app/lotus/controller"
+	"github.com/lotusload/lotus/pkg/app/lotus/kubeclient"
 	"github.com/lotusload/lotus/pkg/cli"
+	"github.com/lotusload/lotus/pkg/metrics"
 )
 
 type controller struct {
@@ -44,12 +46,15 @@ type controller struct {
 	release                  string
 	prometheusServiceAccount string
 	configFile               string
+	metricsPort              int
+	enableTriggers           bool
 }
 
 func NewCommand() *cobra.Command {
 	c := &controller{
-		namespace: "default",
-		release:   "lotus",
+		namespace:   "default",
+		release:     "lotus",
+		metricsPort: 9090,
 	}
 	cmd := &cobra.Command{
 		Use:   "controller",
@@ -62,6 +67,8 @@ func NewCommand() *cobra.Command {
 	cmd.Flags().StringVar(&c.release, "release", c.release, "The release name of deployment.")
 	cmd.Flags().StringVar(&c.prometheusServiceAccount, "prometheus-service-account", c.prometheusServiceAccount, "The name of service account for prometheus pods. This is required when rbac is enabled.")
 	cmd.Flags().StringVar(&c.configFile, "config-file", c.configFile, "Path to the configuration file.")
+	cmd.Flags().IntVar(&c.metricsPort, "metrics-port", c.metricsPort, "The port number to expose the controller metrics.")
+	cmd.Flags().BoolVar(&c.enableTriggers, "enable-triggers", c.enableTriggers, "Whether to run Lotuses from LotusTriggers when their target workloads are rolled out.")
 	cmd.MarkFlagRequired("config-file")
 	return cmd
 }
@@ -85,6 +92,18 @@ func (c *controller) run(ctx context.Context, logger *zap.Logger) error {
 		return err
 	}
 
+	ms, err := metrics.NewServer(
+		c.metricsPort,
+		metrics.WithLogger(logger.Sugar()),
+		metrics.WithCustomViews(kubeclient.DriftCorrectionCountView),
+	)
+	if err != nil {
+		logger.Error("failed to create metrics server", zap.Error(err))
+		return err
+	}
+	defer ms.Stop()
+	go ms.Run()
+
 	kubeInformerFactory := kubeinformers.NewSharedInformerFactoryWithOptions(
 		kubeClient,
 		30*time.Second,
@@ -109,8 +128,30 @@ func (c *controller) run(ctx context.Context, logger *zap.Logger) error {
 		logger,
 	)
 
+	var triggerController *lotus.TriggerController
+	var workloadInformerFactory kubeinformers.SharedInformerFactory
+	if c.enableTriggers {
+		// Trigger targets can be in any namespace.
+		workloadInformerFactory = kubeinformers.NewSharedInformerFactory(kubeClient, 30*time.Second)
+		triggerController = lotus.NewTriggerController(
+			lotusClient,
+			workloadInformerFactory.Apps().V1().Deployments(),
+			workloadInformerFactory.Apps().V1().StatefulSets(),
+			lotusInformerFactory.Lotus().V1beta1().LotusTriggers(),
+			logger,
+		)
+	}
+
 	kubeInformerFactory.Start(ctx.Done())
 	lotusInformerFactory.Start(ctx.Done())
+	if workloadInformerFactory != nil {
+		workloadInformerFactory.Start(ctx.Done())
+		go func() {
+			if err := triggerController.Run(ctx, 1); err != nil {
+				logger.Error("failed to run trigger controller", zap.Error(err))
+			}
+		}()
+	}
 
 	if err = controller.Run(ctx, 1); err != nil {
 		logger.Error("failed to run controller", zap.Error(err))
@@ -0,0 +1,116 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/lotusload/lotus/pkg/app/lotus/config"
+	"github.com/lotusload/lotus/pkg/app/lotus/datasource"
+	"github.com/lotusload/lotus/pkg/app/lotus/model"
+)
+
+// evaluateAssertions evaluates all assertions over the window from start to end.
+func (m *monitor) evaluateAssertions(ctx context.Context, start, end time.Time) []model.AssertionResult {
+	if m.cfg == nil {
+		return nil
+	}
+	results := make([]model.AssertionResult, 0, len(m.cfg.Assertions))
+	for _, a := range m.cfg.Assertions {
+		result := model.AssertionResult{
+			Name:      a.Name,
+			Expr:      a.Expr,
+			Operator:  a.Operator,
+			Threshold: a.Threshold,
+			Value:     model.NoDataValue,
+		}
+		value, err := m.queryAssertion(ctx, a, start, end)
+		if err != nil {
+			m.logger.Error("failed to evaluate assertion", zap.String("assertion", a.Name), zap.Error(err))
+			result.Error = err.Error()
+			results = append(results, result)
+			continue
+		}
+		result.Value = value
+		result.Passed, err = compare(value, a.Operator, a.Threshold)
+		if err != nil {
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func (m *monitor) queryAssertion(ctx context.Context, a *config.Assertion, start, end time.Time) (float64, error) {
+	ds, ok := m.dataSourceMap[a.DataSource]
+	if !ok {
+		return 0, fmt.Errorf("missing datasource: %s", a.DataSource)
+	}
+	samples, err := ds.Query(ctx, datasource.ExpandRange(a.Expr, end.Sub(start)), end)
+	if err != nil {
+		return 0, err
+	}
+	if len(samples) != 1 {
+		return 0, fmt.Errorf("expected a single value but got %d", len(samples))
+	}
+	return samples[0].Value, nil
+}
+
+func compare(value float64, operator string, threshold float64) (bool, error) {
+	switch operator {
+	case "<":
+		return value < threshold, nil
+	case "<=":
+		return value <= threshold, nil
+	case ">":
+		return value > threshold, nil
+	case ">=":
+		return value >= threshold, nil
+	case "==":
+		return value == threshold, nil
+	case "!=":
+		return value != threshold, nil
+	}
+	return false, fmt.Errorf("unsupported operator: %s", operator)
+}
+
+// failedAssertions returns the names of the assertions which did not pass.
+func failedAssertions(results []model.AssertionResult) []string {
+	names := make([]string, 0)
+	for _, r := range results {
+		if !r.Passed {
+			names = append(names, r.Name)
+		}
+	}
+	return names
+}
+
+type assertionError struct {
+	Failed []string
+}
+
+func (ae assertionError) Error() string {
+	return fmt.Sprintf("%d assertions are failed", len(ae.Failed))
+}
@@ -0,0 +1,123 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package monitor
+
+import (
+	"time"
+
+	"github.com/lotusload/lotus/pkg/app/lotus/datasource"
+	"github.com/lotusload/lotus/pkg/app/lotus/model"
+)
+
+// firedCheckTracker records the periods during which each check was firing
+// with the evaluations leading to and during each period.
+// The evaluations of a check which does not fire are dropped: its expression
+// returns no value while it is inactive, so they would only tell that nothing happened.
+type firedCheckTracker struct {
+	// The evaluated checks by name, which decide the severity and the peak value of their periods.
+	checks map[string]datasource.Check
+	// The index in fired of the current period of each firing check.
+	firing map[string]int
+	// The evaluations of each pending check, which become part of the period once it fires.
+	pending map[string][]model.CheckEvaluation
+	fired   []model.FiredCheck
+}
+
+func newFiredCheckTracker() *firedCheckTracker {
+	return &firedCheckTracker{
+		checks:  make(map[string]datasource.Check),
+		firing:  make(map[string]int),
+		pending: make(map[string][]model.CheckEvaluation),
+		fired:   make([]model.FiredCheck, 0),
+	}
+}
+
+// update records the evaluations of the given checks at now.
+func (t *firedCheckTracker) update(checks []datasource.Check, evaluations []datasource.CheckEvaluation, now time.Time) {
+	for _, check := range checks {
+		t.checks[check.Name] = check
+	}
+	for _, e := range evaluations {
+		i, firing := t.firing[e.Name]
+		switch {
+		case firing:
+			t.fired[i].Evaluations = append(t.fired[i].Evaluations, e.CheckEvaluation)
+			if e.State != model.CheckFiring {
+				t.resolve(i, now)
+				delete(t.firing, e.Name)
+			}
+		case e.State == model.CheckFiring:
+			t.firing[e.Name] = len(t.fired)
+			t.fired = append(t.fired, model.FiredCheck{
+				Name:           e.Name,
+				Severity:       t.checks[e.Name].Severity,
+				FiredTimestamp: now,
+				Evaluations:    append(t.pending[e.Name], e.CheckEvaluation),
+			})
+			delete(t.pending, e.Name)
+		case e.State == model.CheckPending:
+			t.pending[e.Name] = append(t.pending[e.Name], e.CheckEvaluation)
+		default:
+			delete(t.pending, e.Name)
+		}
+	}
+}
+
+func (t *firedCheckTracker) resolve(i int, now time.Time) {
+	fired := &t.fired[i]
+	fired.ResolvedTimestamp = now
+	fired.Duration = now.Sub(fired.FiredTimestamp)
+	fired.PeakValue = model.NoDataValue
+	check := t.checks[fired.Name]
+	for _, e := range fired.Evaluations {
+		if check.Worse(e.Value, fired.PeakValue) {
+			fired.PeakValue = e.Value
+		}
+	}
+}
+
+// finish resolves the checks still firing at the end of the test
+// and returns all recorded periods.
+func (t *firedCheckTracker) finish(now time.Time) []model.FiredCheck {
+	for name, i := range t.firing {
+		t.resolve(i, now)
+		delete(t.firing, name)
+	}
+	return t.fired
+}
+
+// failedChecks returns the names of the checks whose severity
+// makes the test fail, in order of their first firing.
+func failedChecks(fired []model.FiredCheck) []string {
+	names := make([]string, 0)
+	seen := make(map[string]struct{})
+	for _, c := range fired {
+		if c.Severity == model.CheckSeverityWarn {
+			continue
+		}
+		if _, ok := seen[c.Name]; ok {
+			continue
+		}
+		seen[c.Name] = struct{}{}
+		names = append(names, c.Name)
+	}
+	return names
+}
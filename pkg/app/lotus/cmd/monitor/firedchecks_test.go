@@ -0,0 +1,126 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package monitor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lotusload/lotus/pkg/app/lotus/datasource"
+	"github.com/lotusload/lotus/pkg/app/lotus/model"
+)
+
+func TestFiredCheckTracker(t *testing.T) {
+	checks := []datasource.Check{
+		datasource.Check{Name: "HighLatency", Severity: model.CheckSeverityFail},
+		datasource.Check{Name: "HighMemory", Severity: model.CheckSeverityWarn},
+		datasource.Check{Name: "LowRPS", Expr: "sum(rate(requests[1m])) <= 100", Severity: model.CheckSeverityWarn},
+	}
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	at := func(minutes int) time.Time {
+		return start.Add(time.Duration(minutes) * time.Minute)
+	}
+	evaluation := func(name string, minutes int, state model.CheckState, value float64) datasource.CheckEvaluation {
+		return datasource.CheckEvaluation{
+			Name:            name,
+			CheckEvaluation: model.CheckEvaluation{Timestamp: at(minutes), State: state, Value: value},
+		}
+	}
+	tracker := newFiredCheckTracker()
+	tracker.update(checks, []datasource.CheckEvaluation{
+		evaluation("HighLatency", 0, model.CheckFiring, 410),
+		evaluation("HighMemory", 0, model.CheckPending, 900),
+		evaluation("LowRPS", 0, model.CheckFiring, 80),
+	}, at(0))
+	tracker.update(checks, []datasource.CheckEvaluation{
+		evaluation("HighLatency", 1, model.CheckFiring, 450),
+		evaluation("HighMemory", 1, model.CheckFiring, 1200),
+		evaluation("LowRPS", 1, model.CheckFiring, 60),
+	}, at(1))
+	tracker.update(checks, []datasource.CheckEvaluation{
+		evaluation("HighLatency", 2, model.CheckInactive, model.NoDataValue),
+		evaluation("HighMemory", 2, model.CheckFiring, 1100),
+		evaluation("LowRPS", 2, model.CheckInactive, model.NoDataValue),
+	}, at(2))
+	tracker.update(checks, []datasource.CheckEvaluation{
+		evaluation("HighLatency", 3, model.CheckFiring, 350),
+		evaluation("HighMemory", 3, model.CheckFiring, 1000),
+	}, at(3))
+	fired := tracker.finish(at(5))
+
+	assert.Equal(t, []model.FiredCheck{
+		model.FiredCheck{
+			Name:              "HighLatency",
+			Severity:          model.CheckSeverityFail,
+			FiredTimestamp:    at(0),
+			ResolvedTimestamp: at(2),
+			Duration:          2 * time.Minute,
+			PeakValue:         450,
+			Evaluations: []model.CheckEvaluation{
+				evaluation("HighLatency", 0, model.CheckFiring, 410).CheckEvaluation,
+				evaluation("HighLatency", 1, model.CheckFiring, 450).CheckEvaluation,
+				evaluation("HighLatency", 2, model.CheckInactive, model.NoDataValue).CheckEvaluation,
+			},
+		},
+		model.FiredCheck{
+			Name:              "LowRPS",
+			Severity:          model.CheckSeverityWarn,
+			FiredTimestamp:    at(0),
+			ResolvedTimestamp: at(2),
+			Duration:          2 * time.Minute,
+			// The lowest value is the peak of a lower bound.
+			PeakValue: 60,
+			Evaluations: []model.CheckEvaluation{
+				evaluation("LowRPS", 0, model.CheckFiring, 80).CheckEvaluation,
+				evaluation("LowRPS", 1, model.CheckFiring, 60).CheckEvaluation,
+				evaluation("LowRPS", 2, model.CheckInactive, model.NoDataValue).CheckEvaluation,
+			},
+		},
+		model.FiredCheck{
+			Name:              "HighMemory",
+			Severity:          model.CheckSeverityWarn,
+			FiredTimestamp:    at(1),
+			ResolvedTimestamp: at(5),
+			Duration:          4 * time.Minute,
+			PeakValue:         1200,
+			Evaluations: []model.CheckEvaluation{
+				evaluation("HighMemory", 0, model.CheckPending, 900).CheckEvaluation,
+				evaluation("HighMemory", 1, model.CheckFiring, 1200).CheckEvaluation,
+				evaluation("HighMemory", 2, model.CheckFiring, 1100).CheckEvaluation,
+				evaluation("HighMemory", 3, model.CheckFiring, 1000).CheckEvaluation,
+			},
+		},
+		model.FiredCheck{
+			Name:              "HighLatency",
+			Severity:          model.CheckSeverityFail,
+			FiredTimestamp:    at(3),
+			ResolvedTimestamp: at(5),
+			Duration:          2 * time.Minute,
+			PeakValue:         350,
+			Evaluations: []model.CheckEvaluation{
+				evaluation("HighLatency", 3, model.CheckFiring, 350).CheckEvaluation,
+			},
+		},
+	}, fired)
+	assert.Equal(t, []string{"HighLatency"}, failedChecks(fired))
+}
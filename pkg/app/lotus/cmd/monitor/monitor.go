@@ -22,7 +22,12 @@ package monitor
 
 import (
 	"context"
+	"crypto/subtle"
+	"errors"
 	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
@@ -39,17 +44,32 @@ import (
 
 type monitor struct {
 	testID                   string
+	lotusName                string
+	testedImage              string
 	runTime                  time.Duration
 	checkInterval            time.Duration
 	checkInitialDelay        time.Duration
+	interimReportInterval    time.Duration
 	collectSummaryDataSource string
 	collectAndReportTimeout  time.Duration
 	configFile               string
+	port                     int
+	stopTokenFile            string
 
-	dataSourceMap map[string]datasource.DataSource
-	checkMap      map[string][]datasource.Check
-	cfg           *config.Config
-	logger        *zap.Logger
+	startTime      time.Time
+	stopToken      string
+	stopCh         chan stopRequest
+	stopReason     string
+	stopConditions []*config.StopCondition
+	dataSourceMap  map[string]datasource.DataSource
+	checkerMap     map[string]datasource.Checker
+	retryPolicyMap map[string]config.RetryPolicy
+	checkMap       map[string][]datasource.Check
+	failuresMap    map[string]int
+	firedChecks    *firedCheckTracker
+	progress       *progressTracker
+	cfg            *config.Config
+	logger         *zap.Logger
 }
 
 func NewCommand() *cobra.Command {
@@ -58,6 +78,10 @@ func NewCommand() *cobra.Command {
 		checkInterval:           30 * time.Second,
 		checkInitialDelay:       10 * time.Second,
 		collectAndReportTimeout: 30 * time.Minute,
+		port:                    9091,
+		stopCh:                  make(chan stopRequest, 1),
+		firedChecks:             newFiredCheckTracker(),
+		progress:                newProgressTracker(),
 	}
 	cmd := &cobra.Command{
 		Use:   "monitor",
@@ -66,29 +90,45 @@ func NewCommand() *cobra.Command {
 	}
 	cmd.Flags().StringVar(&m.testID, "test-id", m.testID, "The unique test id")
 	cmd.MarkFlagRequired("test-id")
+	cmd.Flags().StringVar(&m.lotusName, "lotus-name", m.lotusName, "The name of the tested Lotus, which identifies its Grafana dashboards")
+	cmd.Flags().StringVar(&m.testedImage, "tested-image", m.testedImage, "The image of the target service under test")
 	cmd.Flags().DurationVar(&m.runTime, "run-time", m.runTime, "How long the worker should be run")
 	cmd.Flags().DurationVar(&m.checkInterval, "check-interval", m.checkInterval, "How often does the monitor run the check")
 	cmd.Flags().DurationVar(&m.checkInitialDelay, "check-initial-delay", m.checkInitialDelay, "How long the monitor should wait before performing the first check")
+	cmd.Flags().DurationVar(&m.interimReportInterval, "interim-report-interval", m.interimReportInterval, "How often does the monitor send a partial result to the receivers opted in to interim reports. Zero disables interim reports")
 	cmd.Flags().StringVar(&m.collectSummaryDataSource, "collect-summary-datasource", m.collectSummaryDataSource, "The datasource used to collect test summary")
 	cmd.MarkFlagRequired("collect-summary-datasource")
 	cmd.Flags().DurationVar(&m.collectAndReportTimeout, "collect-and-report-timeout", m.collectAndReportTimeout, "How log to wait for collect and report tasks")
 	cmd.Flags().StringVar(&m.configFile, "config-file", m.configFile, "Path to the configuration file")
 	cmd.MarkFlagRequired("config-file")
+	cmd.Flags().IntVar(&m.port, "port", m.port, "The port number used to serve the monitor HTTP endpoints")
+	cmd.Flags().StringVar(&m.stopTokenFile, "stop-token-file", m.stopTokenFile, "Path to the file containing the bearer token required by the stop requests")
 	return cmd
 }
 
 func (m *monitor) run(ctx context.Context, logger *zap.Logger) (lastErr error) {
 	startTime := time.Now()
+	m.startTime = startTime
 	m.logger = logger.Named("monitor")
+	m.progress.start(m.testID, startTime)
 	ctx, cancel := context.WithTimeout(ctx, m.runTime)
 	defer cancel()
 
 	defer func() {
-		if err := m.collectAndReport(startTime, time.Now(), lastErr); err != nil {
+		finishTime := time.Now()
+		fired := m.firedChecks.finish(finishTime)
+		// The checks with fail severity let the test run its full duration.
+		if failed := failedChecks(fired); lastErr == nil && len(failed) > 0 {
+			lastErr = checkError{
+				Actives: failed,
+			}
+		}
+		if err := m.collectAndReport(startTime, finishTime, fired, lastErr); err != nil {
 			lastErr = err
 		}
 	}()
 
+	// The config is loaded first so that the failures below are reported to its receivers.
 	cfg, err := config.FromFile(m.configFile)
 	if err != nil {
 		logger.Error("failed to load configuration", zap.Error(err))
@@ -96,6 +136,28 @@ func (m *monitor) run(ctx context.Context, logger *zap.Logger) (lastErr error) {
 		return
 	}
 	m.cfg = cfg
+	if m.stopTokenFile != "" {
+		token, err := ioutil.ReadFile(m.stopTokenFile)
+		if err != nil {
+			m.logger.Error("failed to read stop token file", zap.Error(err))
+			lastErr = err
+			return
+		}
+		m.stopToken = strings.TrimSpace(string(token))
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/stop", m.handleStop)
+	mux.HandleFunc("/progress", m.handleProgress)
+	server := &http.Server{
+		Addr:    fmt.Sprintf(":%d", m.port),
+		Handler: mux,
+	}
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			m.logger.Error("failed to run http server", zap.Error(err))
+		}
+	}()
+	defer server.Shutdown(context.Background())
 	dataSourceMap, err := buildDataSourceMap(cfg, logger)
 	if err != nil {
 		logger.Error("failed to build dataSourceMap", zap.Error(err))
@@ -103,15 +165,37 @@ func (m *monitor) run(ctx context.Context, logger *zap.Logger) (lastErr error) {
 		return
 	}
 	m.dataSourceMap = dataSourceMap
-	m.checkMap = buildCheckMap(cfg)
+	m.retryPolicyMap, err = buildRetryPolicyMap(cfg)
+	if err != nil {
+		logger.Error("failed to build retryPolicyMap", zap.Error(err))
+		lastErr = err
+		return
+	}
+	m.checkMap, err = buildCheckMap(cfg)
+	if err != nil {
+		logger.Error("failed to build checkMap", zap.Error(err))
+		lastErr = err
+		return
+	}
+	m.checkerMap = buildCheckerMap(dataSourceMap)
+	m.failuresMap = make(map[string]int, len(m.checkMap))
+	m.stopConditions = stopConditions(cfg, m.collectSummaryDataSource, m.testID)
 
 	// Waiting for initial delay
 	select {
 	case <-time.After(m.checkInitialDelay):
 	case <-ctx.Done():
+	case req := <-m.stopCh:
+		m.logger.Info("stopping the monitor due to a stop request")
+		m.stopReason, lastErr = req.reason, req.err
+		return
 	}
 
 	tick := time.Tick(m.checkInterval)
+	var interimTick <-chan time.Time
+	if m.interimReportInterval > 0 {
+		interimTick = time.Tick(m.interimReportInterval)
+	}
 	for {
 		select {
 		case <-tick:
@@ -119,39 +203,177 @@ func (m *monitor) run(ctx context.Context, logger *zap.Logger) (lastErr error) {
 			if lastErr != nil {
 				return
 			}
+			if name := m.metStopCondition(ctx, time.Now()); name != "" {
+				m.logger.Info("breaking the check loop due to a stop condition", zap.String("condition", name))
+				m.stopReason = fmt.Sprintf("stop condition %s was met", name)
+				return
+			}
+		case now := <-interimTick:
+			m.reportInterim(ctx, startTime, now)
 		case <-ctx.Done():
 			m.logger.Info("breaking the check loop due to the context deadline")
+			m.stopReason = "runTime expired"
+			return
+		case req := <-m.stopCh:
+			m.logger.Info("breaking the check loop due to a stop request")
+			m.stopReason, lastErr = req.reason, req.err
+			return
+		}
+	}
+}
+
+type stopRequest struct {
+	reason string
+	err    error
+}
+
+// handleStop ends the check loop before runTime expires.
+// The optional "error" form value is used as the failure reason of the test,
+// the optional "reason" form value is recorded as why the test was stopped.
+// When a stop token is configured, the request must carry it as a bearer token.
+func (m *monitor) handleStop(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if m.stopToken != "" {
+		given := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(given, []byte("Bearer "+m.stopToken)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
 			return
 		}
 	}
+	req := stopRequest{
+		reason: r.FormValue("reason"),
+	}
+	if reason := r.FormValue("error"); reason != "" {
+		req.err = errors.New(reason)
+		req.reason = reason
+	}
+	if req.reason == "" {
+		req.reason = "stop requested"
+	}
+	select {
+	case m.stopCh <- req:
+	default:
+	}
+	w.WriteHeader(http.StatusOK)
 }
 
 func (m *monitor) check(ctx context.Context) error {
+	now := time.Now()
 	actives := make([]string, 0)
 	m.logger.Info("start checking all datasources", zap.Int("num", len(m.dataSourceMap)))
 	for dsn, checks := range m.checkMap {
-		ds, ok := m.dataSourceMap[dsn]
+		checker, ok := m.checkerMap[dsn]
 		if !ok {
 			err := fmt.Errorf("missing datasource: %s", dsn)
 			m.logger.Error("failed to get datasource", zap.Error(err))
 			return err
 		}
-		result, err := ds.Check(ctx, checks)
+		result, err := m.checkDataSource(ctx, dsn, checker, checks)
 		if err != nil {
-			m.logger.Error("failed to check", zap.Error(err))
-			return err
+			m.failuresMap[dsn]++
+			failures := m.failuresMap[dsn]
+			policy := m.retryPolicyMap[dsn]
+			m.logger.Error("failed to check",
+				zap.String("datasource", dsn),
+				zap.Int("consecutive-failures", failures),
+				zap.Int("max-consecutive-failures", policy.MaxConsecutiveFailures),
+				zap.Error(err),
+			)
+			m.progress.recordCheck(dsn, now, nil, failures, err)
+			if failures >= policy.MaxConsecutiveFailures {
+				return dataSourceUnavailableError{
+					DataSource: dsn,
+					Failures:   failures,
+					Err:        err,
+				}
+			}
+			continue
 		}
+		m.failuresMap[dsn] = 0
+		m.progress.recordCheck(dsn, now, result, 0, nil)
+		if len(result.Pendings) > 0 {
+			m.logger.Info("pending checks", zap.String("datasource", dsn), zap.Any("pendings", result.Pendings))
+		}
+		m.firedChecks.update(checks, result.Evaluations, now)
 		actives = append(actives, result.Actives...)
 	}
+	m.refreshProgress(ctx, now, actives)
 	if len(actives) == 0 {
 		return nil
 	}
 	m.logger.Info("active checks", zap.Any("actives", actives))
+	aborts := make([]string, 0, len(actives))
+	for _, check := range m.checksByName(actives) {
+		if check.Severity == model.CheckSeverityAbort {
+			aborts = append(aborts, check.Name)
+		}
+	}
+	if len(aborts) == 0 {
+		return nil
+	}
 	return checkError{
-		Actives: actives,
+		Actives: aborts,
 	}
 }
 
+func (m *monitor) checksByName(names []string) []datasource.Check {
+	nameSet := make(map[string]struct{}, len(names))
+	for _, name := range names {
+		nameSet[name] = struct{}{}
+	}
+	checks := make([]datasource.Check, 0, len(names))
+	for _, list := range m.checkMap {
+		for _, check := range list {
+			if _, ok := nameSet[check.Name]; ok {
+				checks = append(checks, check)
+			}
+		}
+	}
+	return checks
+}
+
+// checkDataSource runs the checks against the given datasource,
+// retrying with backoff as specified by the retry policy of the datasource.
+func (m *monitor) checkDataSource(ctx context.Context, dsn string, checker datasource.Checker, checks []datasource.Check) (*datasource.CheckResult, error) {
+	policy := m.retryPolicyMap[dsn]
+	for attempt := 1; ; attempt++ {
+		result, err := checker.Check(ctx, checks)
+		if err == nil {
+			return result, nil
+		}
+		if attempt >= policy.MaxAttempts {
+			return nil, err
+		}
+		backoff := policy.Backoff(attempt + 1)
+		m.logger.Warn("failed to check, retrying",
+			zap.String("datasource", dsn),
+			zap.Int("attempt", attempt),
+			zap.Duration("backoff", backoff),
+			zap.Error(err),
+		)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return nil, err
+		}
+	}
+}
+
+// dataSourceUnavailableError is returned when a datasource has failed
+// too many consecutive check rounds for the test result to be trusted.
+type dataSourceUnavailableError struct {
+	DataSource string
+	Failures   int
+	Err        error
+}
+
+func (de dataSourceUnavailableError) Error() string {
+	return fmt.Sprintf("DataSourceUnavailable: datasource %s failed %d consecutive checks: %v", de.DataSource, de.Failures, de.Err)
+}
+
 type checkError struct {
 	Actives []string
 }
@@ -160,23 +382,39 @@ func (ce checkError) Error() string {
 	return fmt.Sprintf("%d checks are failed", len(ce.Actives))
 }
 
-func (m *monitor) collectAndReport(startTime, finishTime time.Time, lastErr error) error {
+func (m *monitor) collectAndReport(startTime, finishTime time.Time, fired []model.FiredCheck, lastErr error) error {
 	ctx, cancel := context.WithTimeout(context.Background(), m.collectAndReportTimeout)
 	defer cancel()
 	result := &model.Result{
 		TestID:            m.testID,
+		LotusName:         m.lotusName,
+		TestedImage:       m.testedImage,
 		Status:            model.TestSucceeded,
+		FiredChecks:       fired,
 		StartedTimestamp:  startTime,
 		FinishedTimestamp: finishTime,
+		RunDuration:       finishTime.Sub(startTime),
+		StopReason:        m.stopReason,
 	}
 	if lastErr != nil {
 		result.SetFailed(lastErr.Error())
 	}
-	if ce, ok := lastErr.(checkError); ok {
-		result.FailedChecks = ce.Actives
+	if _, ok := lastErr.(checkError); ok {
+		result.FailedChecks = failedChecks(fired)
 	}
 
-	summary, collectErr := m.collect(ctx)
+	var assertErr error
+	result.Assertions = m.evaluateAssertions(ctx, startTime, finishTime)
+	if failed := failedAssertions(result.Assertions); len(failed) > 0 {
+		assertErr = assertionError{
+			Failed: failed,
+		}
+		if result.Status != model.TestFailed {
+			result.SetFailed(assertErr.Error())
+		}
+	}
+
+	summary, collectErr := m.collect(ctx, startTime, finishTime)
 	if collectErr != nil {
 		m.logger.Error("failed to collect metrics summary", zap.Error(collectErr))
 		if result.Status != model.TestFailed {
@@ -184,6 +422,12 @@ func (m *monitor) collectAndReport(startTime, finishTime time.Time, lastErr erro
 		}
 	} else {
 		result.MetricsSummary = summary
+		// The time series is only informational, so the test does not fail without it.
+		timeSeries, err := m.collectTimeSeries(ctx, startTime, finishTime)
+		if err != nil {
+			m.logger.Error("failed to collect time series", zap.Error(err))
+		}
+		result.TimeSeries = timeSeries
 	}
 	if m.cfg != nil {
 		result.SetGrafanaDashboardURLs(m.cfg.GrafanaBaseUrl)
@@ -192,22 +436,99 @@ func (m *monitor) collectAndReport(startTime, finishTime time.Time, lastErr erro
 		m.logger.Error("failed to report result", zap.Error(err))
 		return err
 	}
-	return collectErr
+	if collectErr != nil {
+		return collectErr
+	}
+	return assertErr
+}
+
+// collect collects the metrics summary of the test between start and end.
+func (m *monitor) collect(ctx context.Context, start, end time.Time) (*model.MetricsSummary, error) {
+	ds, err := m.summaryDataSource()
+	if err != nil {
+		return nil, err
+	}
+	return ds.CollectSummary(ctx, m.summaryQuery(start, end))
 }
 
-func (m *monitor) collect(ctx context.Context) (*model.MetricsSummary, error) {
+// collectTimeSeries collects the downsampled time series of the test between start and end.
+func (m *monitor) collectTimeSeries(ctx context.Context, start, end time.Time) (*model.TimeSeries, error) {
+	ds, err := m.summaryDataSource()
+	if err != nil {
+		return nil, err
+	}
+	return ds.CollectTimeSeries(ctx, m.summaryQuery(start, end))
+}
+
+func (m *monitor) summaryDataSource() (datasource.DataSource, error) {
 	ds, ok := m.dataSourceMap[m.collectSummaryDataSource]
 	if !ok {
 		err := fmt.Errorf("missing datasource for collecting test summary: %s", m.collectSummaryDataSource)
 		m.logger.Error("failed to get datasource", zap.Error(err))
 		return nil, err
 	}
-	return ds.CollectSummary(ctx, time.Now())
+	return ds, nil
+}
+
+func (m *monitor) summaryQuery(start, end time.Time) datasource.SummaryQuery {
+	return datasource.SummaryQuery{
+		TestID:           m.testID,
+		Start:            start,
+		End:              end,
+		LatencyQuantiles: m.cfg.LatencyQuantiles(),
+		FailureCriteria:  m.cfg.GetFailureCriteria(),
+		Metrics:          m.cfg.GetSummary().GetMetrics(),
+	}
 }
 
+// reportInterim sends a partial result of the running test to the receivers opted in to interim reports.
+// Failures are only logged so that they do not affect the test.
+func (m *monitor) reportInterim(ctx context.Context, startTime, now time.Time) {
+	receivers := interimReceivers(m.cfg)
+	if len(receivers) == 0 {
+		return
+	}
+	result := &model.Result{
+		TestID:            m.testID,
+		LotusName:         m.lotusName,
+		TestedImage:       m.testedImage,
+		Status:            model.TestInProgress,
+		StartedTimestamp:  startTime,
+		FinishedTimestamp: now,
+		RunDuration:       now.Sub(startTime),
+	}
+	summary, err := m.collect(ctx, startTime, now)
+	if err != nil {
+		m.logger.Error("failed to collect interim metrics summary", zap.Error(err))
+		return
+	}
+	result.MetricsSummary = summary
+	result.SetGrafanaDashboardURLs(m.cfg.GrafanaBaseUrl)
+	if err := m.reportTo(ctx, receivers, result); err != nil {
+		m.logger.Error("failed to report interim result", zap.Error(err))
+		return
+	}
+	m.logger.Info("reported interim result", zap.Duration("elapsed", result.RunDuration))
+}
+
+func interimReceivers(cfg *config.Config) []*config.Receiver {
+	receivers := make([]*config.Receiver, 0)
+	for _, recv := range cfg.Receivers {
+		if recv.InterimReports {
+			receivers = append(receivers, recv)
+		}
+	}
+	return receivers
+}
+
+// report sends the result to all receivers, or to none if the config could not be loaded.
 func (m *monitor) report(ctx context.Context, result *model.Result) error {
-	rs := make([]reporter.Reporter, 0, len(m.cfg.Receivers))
-	for _, recv := range m.cfg.Receivers {
+	return m.reportTo(ctx, m.cfg.GetReceivers(), result)
+}
+
+func (m *monitor) reportTo(ctx context.Context, receivers []*config.Receiver, result *model.Result) error {
+	rs := make([]reporter.Reporter, 0, len(receivers))
+	for _, recv := range receivers {
 		builder, err := reporterregistry.Default().Get(recv.ReceiverType())
 		if err != nil {
 			return err
@@ -241,19 +562,44 @@ func buildDataSourceMap(cfg *config.Config, logger *zap.Logger) (map[string]data
 	return datasources, nil
 }
 
-func buildCheckMap(cfg *config.Config) map[string][]datasource.Check {
+func buildRetryPolicyMap(cfg *config.Config) (map[string]config.RetryPolicy, error) {
+	policies := make(map[string]config.RetryPolicy, len(cfg.DataSources))
+	for _, ds := range cfg.DataSources {
+		policy, err := cfg.DataSourceRetryPolicy(ds)
+		if err != nil {
+			return nil, err
+		}
+		policies[ds.Name] = policy
+	}
+	return policies, nil
+}
+
+// buildCheckerMap returns the checkers evaluating the checks in the monitor,
+// so that they work against any datasource.
+func buildCheckerMap(dataSourceMap map[string]datasource.DataSource) map[string]datasource.Checker {
+	checkers := make(map[string]datasource.Checker, len(dataSourceMap))
+	for name, ds := range dataSourceMap {
+		checkers[name] = datasource.NewEvaluator(ds)
+	}
+	return checkers
+}
+
+func buildCheckMap(cfg *config.Config) (map[string][]datasource.Check, error) {
 	checkMap := make(map[string][]datasource.Check)
 	for _, check := range cfg.Checks {
 		c := datasource.Check{
-			Name: check.Name,
-			Expr: check.Expr,
-			For:  check.For,
+			Name:     check.Name,
+			Expr:     check.Expr,
+			For:      check.For,
+			Severity: model.CheckSeverity(check.Severity),
 		}
-		if list, ok := checkMap[check.DataSource]; ok {
-			checkMap[check.DataSource] = append(list, c)
-			continue
+		if c.Severity == "" {
+			c.Severity = model.CheckSeverityAbort
+		}
+		if _, err := c.ForDuration(); err != nil {
+			return nil, err
 		}
-		checkMap[check.DataSource] = []datasource.Check{c}
+		checkMap[check.DataSource] = append(checkMap[check.DataSource], c)
 	}
-	return checkMap
+	return checkMap, nil
 }
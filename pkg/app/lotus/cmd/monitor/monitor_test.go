@@ -0,0 +1,70 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package monitor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lotusload/lotus/pkg/app/lotus/config"
+	"github.com/lotusload/lotus/pkg/app/lotus/model"
+)
+
+func TestInterimReceivers(t *testing.T) {
+	cfg := &config.Config{
+		Receivers: []*config.Receiver{
+			&config.Receiver{Name: "gcs"},
+			&config.Receiver{Name: "slack", InterimReports: true},
+		},
+	}
+	receivers := interimReceivers(cfg)
+	assert.Len(t, receivers, 1)
+	assert.Equal(t, "slack", receivers[0].Name)
+}
+
+func TestReportWithoutConfig(t *testing.T) {
+	m := &monitor{}
+	assert.NoError(t, m.report(context.Background(), &model.Result{TestID: "test"}))
+}
+
+func TestHandleStopToken(t *testing.T) {
+	m := &monitor{
+		stopToken: "secret",
+		stopCh:    make(chan stopRequest, 1),
+	}
+	stop := func(authorization string) int {
+		req := httptest.NewRequest(http.MethodPost, "/stop", nil)
+		if authorization != "" {
+			req.Header.Set("Authorization", authorization)
+		}
+		w := httptest.NewRecorder()
+		m.handleStop(w, req)
+		return w.Code
+	}
+	assert.Equal(t, http.StatusUnauthorized, stop(""))
+	assert.Equal(t, http.StatusUnauthorized, stop("Bearer wrong"))
+	assert.Empty(t, m.stopCh)
+	assert.Equal(t, http.StatusOK, stop("Bearer secret"))
+	assert.Equal(t, "stop requested", (<-m.stopCh).reason)
+}
@@ -0,0 +1,161 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package monitor
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/lotusload/lotus/pkg/app/lotus/datasource"
+	"github.com/lotusload/lotus/pkg/app/lotus/model"
+)
+
+// progressSummaryInterval is the minimum interval between the collections of the summary snapshot,
+// which is far more expensive than the rates queried at every check round.
+const progressSummaryInterval = time.Minute
+
+// progressTracker keeps the live progress of the test
+// which is updated by the check loop and served by the HTTP endpoint.
+type progressTracker struct {
+	mu          sync.RWMutex
+	progress    model.Progress
+	dataSources map[string]model.DataSourceProgress
+	lastSummary time.Time
+}
+
+func newProgressTracker() *progressTracker {
+	return &progressTracker{
+		dataSources: make(map[string]model.DataSourceProgress),
+	}
+}
+
+func (t *progressTracker) start(testID string, startTime time.Time) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.TestID = testID
+	t.progress.StartedTimestamp = startTime
+}
+
+// recordCheck records the outcome of a check round against the given datasource.
+func (t *progressTracker) recordCheck(dsn string, ts time.Time, result *datasource.CheckResult, failures int, err error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	p := t.dataSources[dsn]
+	p.Name = dsn
+	p.LastCheckTimestamp = ts
+	p.ConsecutiveFailures = failures
+	p.Error = ""
+	if err != nil {
+		p.Error = err.Error()
+	}
+	if result != nil {
+		p.Actives = result.Actives
+		p.Pendings = result.Pendings
+	}
+	t.dataSources[dsn] = p
+}
+
+// recordSnapshot records the metrics observed at the given time.
+func (t *progressTracker) recordSnapshot(ts time.Time, rps, errorPercentage float64, firing []string, summary *model.MetricsSummary) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.progress.UpdatedTimestamp = ts
+	t.progress.RPS = rps
+	t.progress.ErrorPercentage = errorPercentage
+	t.progress.FiringChecks = firing
+	if summary != nil {
+		t.progress.MetricsSummary = summary
+	}
+}
+
+// summaryDue reports whether progressSummaryInterval has passed since the last summary snapshot,
+// in which case the given time is recorded as the time of the next one.
+func (t *progressTracker) summaryDue(now time.Time) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if now.Sub(t.lastSummary) < progressSummaryInterval {
+		return false
+	}
+	t.lastSummary = now
+	return true
+}
+
+// snapshot returns the progress as of now for a test running for runTime.
+func (t *progressTracker) snapshot(now time.Time, runTime time.Duration) model.Progress {
+	t.mu.RLock()
+	defer t.mu.RUnlock()
+	p := t.progress
+	p.Elapsed = now.Sub(p.StartedTimestamp)
+	p.Remaining = runTime - p.Elapsed
+	if p.Remaining < 0 {
+		p.Remaining = 0
+	}
+	p.DataSources = make([]model.DataSourceProgress, 0, len(t.dataSources))
+	for _, ds := range t.dataSources {
+		p.DataSources = append(p.DataSources, ds)
+	}
+	sort.Slice(p.DataSources, func(i, j int) bool {
+		return p.DataSources[i].Name < p.DataSources[j].Name
+	})
+	return p
+}
+
+// handleProgress serves the live progress of the test as JSON.
+func (m *monitor) handleProgress(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	data, err := json.Marshal(m.progress.snapshot(time.Now(), m.runTime))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(data)
+}
+
+// refreshProgress queries the current rates from the datasource used to collect the summary,
+// and the summary snapshot at most once per progressSummaryInterval.
+// Failures are only logged since the progress is informational.
+func (m *monitor) refreshProgress(ctx context.Context, now time.Time, firing []string) {
+	ds, ok := m.dataSourceMap[m.collectSummaryDataSource]
+	if !ok {
+		return
+	}
+	rps, errorPercentage, err := ds.CollectProgress(ctx, m.summaryQuery(m.startTime, now))
+	if err != nil {
+		m.logger.Warn("failed to collect progress rates", zap.Error(err))
+	}
+	var summary *model.MetricsSummary
+	if m.progress.summaryDue(now) {
+		summary, err = m.collect(ctx, m.startTime, now)
+		if err != nil {
+			m.logger.Warn("failed to collect progress summary", zap.Error(err))
+		}
+	}
+	m.progress.recordSnapshot(now, rps, errorPercentage, firing, summary)
+}
@@ -0,0 +1,64 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package monitor
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lotusload/lotus/pkg/app/lotus/datasource"
+)
+
+func TestProgressTracker(t *testing.T) {
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker := newProgressTracker()
+	tracker.start("test-1", start)
+	tracker.recordCheck("remote", start.Add(time.Minute), nil, 2, errors.New("timeout"))
+	tracker.recordCheck("local", start.Add(time.Minute), &datasource.CheckResult{Actives: []string{"HighLatency"}}, 0, nil)
+	tracker.recordSnapshot(start.Add(time.Minute), 120, 1.5, []string{"HighLatency"}, nil)
+
+	p := tracker.snapshot(start.Add(90*time.Second), 2*time.Minute)
+	assert.Equal(t, "test-1", p.TestID)
+	assert.Equal(t, 90*time.Second, p.Elapsed)
+	assert.Equal(t, 30*time.Second, p.Remaining)
+	assert.Equal(t, 120.0, p.RPS)
+	assert.Equal(t, []string{"HighLatency"}, p.FiringChecks)
+	assert.Len(t, p.DataSources, 2)
+	assert.Equal(t, "local", p.DataSources[0].Name)
+	assert.Equal(t, []string{"HighLatency"}, p.DataSources[0].Actives)
+	assert.Equal(t, "remote", p.DataSources[1].Name)
+	assert.Equal(t, 2, p.DataSources[1].ConsecutiveFailures)
+	assert.Equal(t, "timeout", p.DataSources[1].Error)
+
+	p = tracker.snapshot(start.Add(3*time.Minute), 2*time.Minute)
+	assert.Equal(t, time.Duration(0), p.Remaining)
+}
+
+func TestProgressTrackerSummaryDue(t *testing.T) {
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	tracker := newProgressTracker()
+	assert.True(t, tracker.summaryDue(start))
+	assert.False(t, tracker.summaryDue(start.Add(30*time.Second)))
+	assert.True(t, tracker.summaryDue(start.Add(time.Minute)))
+	assert.False(t, tracker.summaryDue(start.Add(90*time.Second)))
+}
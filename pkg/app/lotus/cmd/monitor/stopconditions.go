@@ -0,0 +1,91 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package monitor
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"go.uber.org/zap"
+
+	"github.com/lotusload/lotus/pkg/app/lotus/config"
+	"github.com/lotusload/lotus/pkg/app/lotus/datasource"
+	"github.com/lotusload/lotus/pkg/app/lotus/model"
+)
+
+// virtualUsersFinishedExpr returns an expression which returns a sample when all started
+// virtual users of the given test have either succeeded or failed.
+// Like the totals of the summary, the virtual users are counted by the max of their sum since the start of the test.
+func virtualUsersFinishedExpr(testID string) string {
+	total := func(status string) string {
+		return fmt.Sprintf(`max_over_time(sum(lotus_virtual_user_count{%s="%s",virtual_user_status=~"%s"})[%s:5s])`,
+			model.TestIDLabel, testID, status, datasource.RangePlaceholder)
+	}
+	started := total("started")
+	return fmt.Sprintf("%s > 0 and %s <= %s", started, started, total("succeeded|failed"))
+}
+
+// stopConditions returns the stop conditions evaluated by the monitor.
+// The virtual users condition is evaluated against the datasource used to collect the summary.
+func stopConditions(cfg *config.Config, summaryDataSource, testID string) []*config.StopCondition {
+	sc := cfg.GetStopConditions()
+	if sc == nil {
+		return nil
+	}
+	conditions := make([]*config.StopCondition, 0, len(sc.Conditions)+1)
+	if sc.VirtualUsersFinished {
+		conditions = append(conditions, &config.StopCondition{
+			Name:       "VirtualUsersFinished",
+			Expr:       virtualUsersFinishedExpr(testID),
+			DataSource: summaryDataSource,
+		})
+	}
+	return append(conditions, sc.Conditions...)
+}
+
+// metStopCondition returns the name of the first stop condition whose expression
+// returns a sample, or an empty string if none of them is met.
+// Conditions which cannot be evaluated are treated as not met.
+func (m *monitor) metStopCondition(ctx context.Context, now time.Time) string {
+	for _, sc := range m.stopConditions {
+		met, err := m.evaluateStopCondition(ctx, sc, now)
+		if err != nil {
+			m.logger.Error("failed to evaluate stop condition", zap.String("condition", sc.Name), zap.Error(err))
+			continue
+		}
+		if met {
+			return sc.Name
+		}
+	}
+	return ""
+}
+
+func (m *monitor) evaluateStopCondition(ctx context.Context, sc *config.StopCondition, now time.Time) (bool, error) {
+	ds, ok := m.dataSourceMap[sc.DataSource]
+	if !ok {
+		return false, fmt.Errorf("missing datasource: %s", sc.DataSource)
+	}
+	samples, err := ds.Query(ctx, datasource.ExpandRange(sc.Expr, now.Sub(m.startTime)), now)
+	if err != nil {
+		return false, err
+	}
+	return len(samples) > 0, nil
+}
@@ -0,0 +1,50 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package monitor
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lotusload/lotus/pkg/app/lotus/config"
+)
+
+func TestStopConditions(t *testing.T) {
+	cfg := &config.Config{}
+	assert.Empty(t, stopConditions(cfg, "local", "test-1"))
+
+	cfg.StopConditions = &config.StopConditions{
+		VirtualUsersFinished: true,
+		Conditions: []*config.StopCondition{
+			&config.StopCondition{Name: "Done", Expr: "up == 0", DataSource: "remote"},
+		},
+	}
+	conditions := stopConditions(cfg, "local", "test-1")
+	assert.Len(t, conditions, 2)
+	assert.Equal(t, "VirtualUsersFinished", conditions[0].Name)
+	assert.Equal(t,
+		`max_over_time(sum(lotus_virtual_user_count{lotus_test_id="test-1",virtual_user_status=~"started"})[$__range:5s]) > 0 and `+
+			`max_over_time(sum(lotus_virtual_user_count{lotus_test_id="test-1",virtual_user_status=~"started"})[$__range:5s]) <= `+
+			`max_over_time(sum(lotus_virtual_user_count{lotus_test_id="test-1",virtual_user_status=~"succeeded|failed"})[$__range:5s])`,
+		conditions[0].Expr)
+	assert.Equal(t, "local", conditions[0].DataSource)
+	assert.Equal(t, "Done", conditions[1].Name)
+}
@@ -23,6 +23,7 @@ package config
 import (
 	"fmt"
 	"io/ioutil"
+	"time"
 
 	"github.com/ghodss/yaml"
 	"github.com/golang/protobuf/jsonpb"
@@ -30,28 +31,101 @@ import (
 	lotusv1beta1 "github.com/lotusload/lotus/pkg/app/lotus/apis/lotus/v1beta1"
 )
 
-func (c *Config) AddChecks(checks ...lotusv1beta1.LotusCheck) {
+// AddChecks adds the given checks, translating their thresholds into expressions
+// which select the metrics of the given test.
+func (c *Config) AddChecks(testID string, checks ...lotusv1beta1.LotusCheck) error {
 	for i := range checks {
-		c.Checks = append(c.Checks, &Check{
+		check := &Check{
 			Name:       checks[i].Name,
 			Expr:       checks[i].Expr,
 			For:        checks[i].For,
 			DataSource: checks[i].DataSource,
+			Severity:   string(checks[i].Severity),
+			Metric:     checks[i].Metric,
+			Quantile:   checks[i].Quantile,
+			Host:       checks[i].Host,
+			Route:      checks[i].Route,
+			Method:     checks[i].Method,
+			Op:         checks[i].Op,
+			Value:      checks[i].Value,
+		}
+		if err := check.resolveThreshold(testID, c.FailureCriteria); err != nil {
+			return err
+		}
+		c.Checks = append(c.Checks, check)
+	}
+	return nil
+}
+
+func (c *Config) AddAssertions(assertions ...lotusv1beta1.LotusAssertion) {
+	for i := range assertions {
+		c.Assertions = append(c.Assertions, &Assertion{
+			Name:       assertions[i].Name,
+			Expr:       assertions[i].Expr,
+			Operator:   string(assertions[i].Operator),
+			Threshold:  assertions[i].Threshold,
+			DataSource: assertions[i].DataSource,
 		})
 	}
 }
 
-func (c *Config) LotusChecks() []lotusv1beta1.LotusCheck {
-	checks := make([]lotusv1beta1.LotusCheck, 0, len(c.Checks))
-	for _, check := range c.Checks {
-		checks = append(checks, lotusv1beta1.LotusCheck{
-			Name:       check.Name,
-			Expr:       check.Expr,
-			For:        check.For,
-			DataSource: check.DataSource,
+var (
+	DefaultLatencyQuantiles = []float64{0.5, 0.9, 0.95, 0.99}
+)
+
+// LatencyQuantiles returns the quantiles of the latency percentiles in the metrics summary.
+func (c *Config) LatencyQuantiles() []float64 {
+	if qs := c.GetSummary().GetLatencyQuantiles(); len(qs) > 0 {
+		return qs
+	}
+	return DefaultLatencyQuantiles
+}
+
+// AddSummaryMetrics adds the user-defined summary metrics of a Lotus.
+// A metric replaces the global one with the same name.
+func (c *Config) AddSummaryMetrics(s *lotusv1beta1.LotusSummary) {
+	if s == nil {
+		return
+	}
+	if c.Summary == nil {
+		c.Summary = &Summary{}
+	}
+	for i := range s.Metrics {
+		metric := &SummaryMetric{
+			Name:    s.Metrics[i].Name,
+			Expr:    s.Metrics[i].Expr,
+			GroupBy: s.Metrics[i].GroupBy,
+		}
+		replaced := false
+		for j, m := range c.Summary.Metrics {
+			if m.Name == metric.Name {
+				c.Summary.Metrics[j] = metric
+				replaced = true
+				break
+			}
+		}
+		if !replaced {
+			c.Summary.Metrics = append(c.Summary.Metrics, metric)
+		}
+	}
+}
+
+// SetStopConditions sets the stop conditions evaluated by the monitor.
+// WorkersTerminated is evaluated by the controller.
+func (c *Config) SetStopConditions(sc *lotusv1beta1.LotusStopConditions) {
+	if sc == nil {
+		return
+	}
+	c.StopConditions = &StopConditions{
+		VirtualUsersFinished: sc.VirtualUsersFinished,
+	}
+	for i := range sc.Conditions {
+		c.StopConditions.Conditions = append(c.StopConditions.Conditions, &StopCondition{
+			Name:       sc.Conditions[i].Name,
+			Expr:       sc.Conditions[i].Expr,
+			DataSource: sc.Conditions[i].DataSource,
 		})
 	}
-	return checks
 }
 
 func (ds *DataSource) DataSourceType() DataSource_Type {
@@ -63,6 +137,66 @@ func (ds *DataSource) DataSourceType() DataSource_Type {
 	}
 }
 
+const (
+	defaultRetryMaxAttempts            = 3
+	defaultRetryInitialBackoff         = time.Second
+	defaultRetryMaxBackoff             = 10 * time.Second
+	defaultRetryMaxConsecutiveFailures = 3
+)
+
+// RetryPolicy is the parsed DataSourceRetry with the defaults applied.
+type RetryPolicy struct {
+	MaxAttempts            int
+	InitialBackoff         time.Duration
+	MaxBackoff             time.Duration
+	MaxConsecutiveFailures int
+}
+
+// Backoff returns the wait time before the given attempt, starting from 1.
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	backoff := p.InitialBackoff
+	for i := 2; i < attempt && backoff < p.MaxBackoff; i++ {
+		backoff *= 2
+	}
+	if backoff > p.MaxBackoff {
+		return p.MaxBackoff
+	}
+	return backoff
+}
+
+// DataSourceRetryPolicy returns the retry policy of the given datasource.
+func (c *Config) DataSourceRetryPolicy(ds *DataSource) (RetryPolicy, error) {
+	policy := RetryPolicy{
+		MaxAttempts:            defaultRetryMaxAttempts,
+		InitialBackoff:         defaultRetryInitialBackoff,
+		MaxBackoff:             defaultRetryMaxBackoff,
+		MaxConsecutiveFailures: defaultRetryMaxConsecutiveFailures,
+	}
+	for _, retry := range []*DataSourceRetry{c.GetDataSourceRetry(), ds.GetRetry()} {
+		if n := retry.GetMaxAttempts(); n > 0 {
+			policy.MaxAttempts = int(n)
+		}
+		if n := retry.GetMaxConsecutiveFailures(); n > 0 {
+			policy.MaxConsecutiveFailures = int(n)
+		}
+		if d := retry.GetInitialBackoff(); d != "" {
+			backoff, err := time.ParseDuration(d)
+			if err != nil {
+				return policy, fmt.Errorf("invalid initialBackoff for datasource %s: %v", ds.Name, err)
+			}
+			policy.InitialBackoff = backoff
+		}
+		if d := retry.GetMaxBackoff(); d != "" {
+			backoff, err := time.ParseDuration(d)
+			if err != nil {
+				return policy, fmt.Errorf("invalid maxBackoff for datasource %s: %v", ds.Name, err)
+			}
+			policy.MaxBackoff = backoff
+		}
+	}
+	return policy, nil
+}
+
 func (r *Receiver) ReceiverType() Receiver_Type {
 	switch r.Type.(type) {
 	case *Receiver_Logger:
@@ -85,14 +219,24 @@ func (r *Receiver) CredentialsFile(filename string) string {
 }
 
 func FromFile(file string) (*Config, error) {
+	return FromFileForTest(file, "", nil)
+}
+
+// FromFileForTest loads the config file for the given test. The failure criteria of its Lotus
+// are merged into the global ones and the thresholds of the checks select the metrics of the test.
+func FromFileForTest(file, testID string, fc *lotusv1beta1.LotusFailureCriteria) (*Config, error) {
 	data, err := ioutil.ReadFile(file)
 	if err != nil {
 		return nil, err
 	}
-	return UnmarshalFromYaml(data)
+	return unmarshalFromYaml(data, testID, fc)
 }
 
 func UnmarshalFromYaml(data []byte) (*Config, error) {
+	return unmarshalFromYaml(data, "", nil)
+}
+
+func unmarshalFromYaml(data []byte, testID string, fc *lotusv1beta1.LotusFailureCriteria) (*Config, error) {
 	json, err := yaml.YAMLToJSON(data)
 	if err != nil {
 		return nil, err
@@ -104,6 +248,22 @@ func UnmarshalFromYaml(data []byte) (*Config, error) {
 	if err := config.Validate(); err != nil {
 		return nil, err
 	}
+	if err := config.SetFailureCriteria(fc); err != nil {
+		return nil, err
+	}
+	for _, check := range config.Checks {
+		if err := check.resolveThreshold(testID, config.FailureCriteria); err != nil {
+			return nil, err
+		}
+	}
+	if _, err := config.DataSourceRetryPolicy(&DataSource{Name: "default"}); err != nil {
+		return nil, err
+	}
+	for _, ds := range config.DataSources {
+		if _, err := config.DataSourceRetryPolicy(ds); err != nil {
+			return nil, err
+		}
+	}
 	return config, nil
 }
 
@@ -22,9 +22,12 @@ package config
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+
+	lotusv1beta1 "github.com/lotusload/lotus/pkg/app/lotus/apis/lotus/v1beta1"
 )
 
 func TestFromFile(t *testing.T) {
@@ -115,3 +118,52 @@ func TestMarshaling(t *testing.T) {
 		assert.Equal(t, cfg, unmarshaledCfg)
 	}
 }
+
+func TestAddSummaryMetrics(t *testing.T) {
+	cfg := &Config{
+		Summary: &Summary{
+			Metrics: []*SummaryMetric{
+				&SummaryMetric{Name: "OrdersPlaced", Expr: "sum(increase(orders_placed_total[$__range]))"},
+			},
+		},
+	}
+	cfg.AddSummaryMetrics(&lotusv1beta1.LotusSummary{
+		Metrics: []lotusv1beta1.LotusSummaryMetric{
+			{Name: "CacheHitRatio", Expr: "sum by (region) (cache_hits) / sum by (region) (cache_requests)", GroupBy: []string{"region"}},
+			{Name: "OrdersPlaced", Expr: "sum(increase(orders_total[$__range]))"},
+		},
+	})
+	require.Equal(t, 2, len(cfg.Summary.Metrics))
+	assert.Equal(t, "sum(increase(orders_total[$__range]))", cfg.Summary.Metrics[0].Expr)
+	assert.Equal(t, []string{"region"}, cfg.Summary.Metrics[1].GroupBy)
+}
+
+func TestDataSourceRetryPolicy(t *testing.T) {
+	cfg := &Config{}
+	policy, err := cfg.DataSourceRetryPolicy(&DataSource{Name: "prometheus"})
+	require.NoError(t, err)
+	assert.Equal(t, 3, policy.MaxAttempts)
+	assert.Equal(t, 3, policy.MaxConsecutiveFailures)
+	assert.Equal(t, time.Second, policy.Backoff(2))
+	assert.Equal(t, 4*time.Second, policy.Backoff(4))
+	assert.Equal(t, 10*time.Second, policy.Backoff(10))
+
+	cfg.DataSourceRetry = &DataSourceRetry{
+		MaxAttempts:            5,
+		MaxConsecutiveFailures: 10,
+	}
+	policy, err = cfg.DataSourceRetryPolicy(&DataSource{
+		Name: "prometheus",
+		Retry: &DataSourceRetry{
+			InitialBackoff:         "500ms",
+			MaxConsecutiveFailures: 2,
+		},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, 5, policy.MaxAttempts)
+	assert.Equal(t, 2, policy.MaxConsecutiveFailures)
+	assert.Equal(t, 500*time.Millisecond, policy.Backoff(2))
+
+	_, err = cfg.DataSourceRetryPolicy(&DataSource{Name: "prometheus", Retry: &DataSourceRetry{MaxBackoff: "10"}})
+	assert.Error(t, err)
+}
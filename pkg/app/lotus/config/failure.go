@@ -0,0 +1,192 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	lotusv1beta1 "github.com/lotusload/lotus/pkg/app/lotus/apis/lotus/v1beta1"
+	"github.com/lotusload/lotus/pkg/metrics/grpcmetrics"
+	"github.com/lotusload/lotus/pkg/metrics/httpmetrics"
+)
+
+const (
+	defaultHTTPFailureStatus    = "5.."
+	defaultGRPCSuccessfulStatus = "OK|NOT_FOUND"
+	// The recording rules of the Grafana dashboards have never counted ALREADY_EXISTS as a failure.
+	defaultDashboardGRPCSuccessfulStatus = "OK|NOT_FOUND|ALREADY_EXISTS"
+)
+
+// SetFailureCriteria merges the failure criteria of a Lotus into the global ones and validates the result.
+// The codes of the Lotus replace the global codes and its overrides are matched first.
+func (c *Config) SetFailureCriteria(fc *lotusv1beta1.LotusFailureCriteria) error {
+	if fc == nil {
+		return c.FailureCriteria.validate()
+	}
+	merged := &FailureCriteria{
+		HttpStatusCodes: c.GetFailureCriteria().GetHttpStatusCodes(),
+		GrpcCodes:       c.GetFailureCriteria().GetGrpcCodes(),
+	}
+	if len(fc.HTTPStatusCodes) > 0 {
+		merged.HttpStatusCodes = fc.HTTPStatusCodes
+	}
+	if len(fc.GRPCCodes) > 0 {
+		merged.GrpcCodes = fc.GRPCCodes
+	}
+	for i := range fc.Overrides {
+		merged.Overrides = append(merged.Overrides, &FailureOverride{
+			HttpRoute:       fc.Overrides[i].HTTPRoute,
+			HttpStatusCodes: fc.Overrides[i].HTTPStatusCodes,
+			GrpcMethod:      fc.Overrides[i].GRPCMethod,
+			GrpcCodes:       fc.Overrides[i].GRPCCodes,
+		})
+	}
+	merged.Overrides = append(merged.Overrides, c.GetFailureCriteria().GetOverrides()...)
+	c.FailureCriteria = merged
+	return merged.validate()
+}
+
+func (fc *FailureCriteria) validate() error {
+	for _, codes := range [][]string{fc.GetHttpStatusCodes(), fc.GetGrpcCodes()} {
+		if err := validateCodes(codes); err != nil {
+			return err
+		}
+	}
+	for i, o := range fc.GetOverrides() {
+		if o.HttpRoute == "" && o.GrpcMethod == "" {
+			return fmt.Errorf("failure override %d: either httpRoute or grpcMethod is required", i)
+		}
+		if (o.HttpRoute == "") != (len(o.HttpStatusCodes) == 0) {
+			return fmt.Errorf("failure override %d: httpRoute and httpStatusCodes must be specified together", i)
+		}
+		if (o.GrpcMethod == "") != (len(o.GrpcCodes) == 0) {
+			return fmt.Errorf("failure override %d: grpcMethod and grpcCodes must be specified together", i)
+		}
+		for _, codes := range [][]string{o.HttpStatusCodes, o.GrpcCodes} {
+			if err := validateCodes(codes); err != nil {
+				return fmt.Errorf("failure override %d: %v", i, err)
+			}
+		}
+	}
+	return nil
+}
+
+func validateCodes(codes []string) error {
+	for _, code := range codes {
+		if _, err := regexp.Compile(code); err != nil {
+			return fmt.Errorf("invalid failure code %q: %v", code, err)
+		}
+	}
+	return nil
+}
+
+// failurePattern is the codes counted as failures for the values of a label matching the pattern.
+type failurePattern struct {
+	pattern string
+	status  labelMatcher
+}
+
+// HTTPFailureMatchers returns the PromQL label matchers selecting the failed HTTP requests.
+// Each of them selects the failures of a disjoint set of routes,
+// so the failures are counted once by combining the matchers with UnionOf.
+func (fc *FailureCriteria) HTTPFailureMatchers() []string {
+	var overrides []failurePattern
+	for _, o := range fc.GetOverrides() {
+		if o.HttpRoute != "" {
+			overrides = append(overrides, failurePattern{pattern: o.HttpRoute, status: codesMatcher(o.HttpStatusCodes)})
+		}
+	}
+	status := labelMatcher{op: "=~", value: defaultHTTPFailureStatus}
+	if codes := fc.GetHttpStatusCodes(); len(codes) > 0 {
+		status = codesMatcher(codes)
+	}
+	return failureMatchers(httpmetrics.KeyClientRoute.Name(), httpmetrics.KeyClientStatus.Name(), overrides, status)
+}
+
+// GRPCFailureMatchers returns the PromQL label matchers selecting the failed RPCs.
+// Each of them selects the failures of a disjoint set of methods,
+// so the failures are counted once by combining the matchers with UnionOf.
+func (fc *FailureCriteria) GRPCFailureMatchers() []string {
+	return fc.grpcFailureMatchers(defaultGRPCSuccessfulStatus)
+}
+
+// DashboardGRPCFailureMatchers is GRPCFailureMatchers for the recording rules of the Grafana dashboards,
+// which do not count ALREADY_EXISTS as a failure unless the gRPC codes are configured.
+func (fc *FailureCriteria) DashboardGRPCFailureMatchers() []string {
+	return fc.grpcFailureMatchers(defaultDashboardGRPCSuccessfulStatus)
+}
+
+func (fc *FailureCriteria) grpcFailureMatchers(defaultSuccessfulStatus string) []string {
+	var overrides []failurePattern
+	for _, o := range fc.GetOverrides() {
+		if o.GrpcMethod != "" {
+			overrides = append(overrides, failurePattern{pattern: o.GrpcMethod, status: codesMatcher(o.GrpcCodes)})
+		}
+	}
+	status := labelMatcher{op: "!~", value: defaultSuccessfulStatus}
+	if codes := fc.GetGrpcCodes(); len(codes) > 0 {
+		status = codesMatcher(codes)
+	}
+	return failureMatchers(grpcmetrics.KeyClientMethod.Name(), grpcmetrics.KeyClientStatus.Name(), overrides, status)
+}
+
+func codesMatcher(codes []string) labelMatcher {
+	return labelMatcher{op: "=~", value: strings.Join(codes, "|")}
+}
+
+// failureMatchers excludes the patterns of the previous overrides from each override,
+// so that the first matching override is used, and all of them from the default codes.
+func failureMatchers(label, statusLabel string, overrides []failurePattern, status labelMatcher) []string {
+	matchers := make([]string, 0, len(overrides)+1)
+	previous := make([]string, 0, len(overrides))
+	for _, o := range overrides {
+		pattern := globRegexp(o.pattern)
+		parts := []string{formatMatcher(label, labelMatcher{op: "=~", value: pattern})}
+		if len(previous) > 0 {
+			parts = append(parts, formatMatcher(label, labelMatcher{op: "!~", value: strings.Join(previous, "|")}))
+		}
+		parts = append(parts, formatMatcher(statusLabel, o.status))
+		matchers = append(matchers, strings.Join(parts, ","))
+		previous = append(previous, pattern)
+	}
+	var parts []string
+	if len(previous) > 0 {
+		parts = append(parts, formatMatcher(label, labelMatcher{op: "!~", value: strings.Join(previous, "|")}))
+	}
+	parts = append(parts, formatMatcher(statusLabel, status))
+	return append(matchers, strings.Join(parts, ","))
+}
+
+func formatMatcher(label string, m labelMatcher) string {
+	return fmt.Sprintf("%s%s%s", label, m.op, strconv.Quote(m.value))
+}
+
+// UnionOf combines the expressions built for each of the disjoint matchers with "or".
+func UnionOf(matchers []string, expr func(matcher string) string) string {
+	exprs := make([]string, 0, len(matchers))
+	for _, m := range matchers {
+		exprs = append(exprs, expr(m))
+	}
+	return strings.Join(exprs, " or ")
+}
@@ -0,0 +1,84 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	lotusv1beta1 "github.com/lotusload/lotus/pkg/app/lotus/apis/lotus/v1beta1"
+)
+
+func TestFailureMatchers(t *testing.T) {
+	var fc *FailureCriteria
+	assert.Equal(t, []string{`http_client_status=~"5.."`}, fc.HTTPFailureMatchers())
+	assert.Equal(t, []string{`grpc_client_status!~"OK|NOT_FOUND"`}, fc.GRPCFailureMatchers())
+	assert.Equal(t, []string{`grpc_client_status!~"OK|NOT_FOUND|ALREADY_EXISTS"`}, fc.DashboardGRPCFailureMatchers())
+
+	fc = &FailureCriteria{
+		GrpcCodes: []string{"INTERNAL", "UNAVAILABLE"},
+		Overrides: []*FailureOverride{
+			{GrpcMethod: "users.Users/Get", GrpcCodes: []string{"INTERNAL"}},
+			{HttpRoute: "/users/*", HttpStatusCodes: []string{"5..", "404"}},
+			{GrpcMethod: "users.Users/*", GrpcCodes: []string{"ALREADY_EXISTS"}},
+		},
+	}
+	assert.Equal(t, []string{
+		`http_client_route=~"/users/.*",http_client_status=~"5..|404"`,
+		`http_client_route!~"/users/.*",http_client_status=~"5.."`,
+	}, fc.HTTPFailureMatchers())
+	assert.Equal(t, []string{
+		`grpc_client_method=~"users\\.Users/Get",grpc_client_status=~"INTERNAL"`,
+		`grpc_client_method=~"users\\.Users/.*",grpc_client_method!~"users\\.Users/Get",grpc_client_status=~"ALREADY_EXISTS"`,
+		`grpc_client_method!~"users\\.Users/Get|users\\.Users/.*",grpc_client_status=~"INTERNAL|UNAVAILABLE"`,
+	}, fc.GRPCFailureMatchers())
+	assert.Equal(t, fc.GRPCFailureMatchers(), fc.DashboardGRPCFailureMatchers())
+}
+
+func TestMergeFailureCriteria(t *testing.T) {
+	cfg := &Config{
+		FailureCriteria: &FailureCriteria{
+			HttpStatusCodes: []string{"5.."},
+			GrpcCodes:       []string{"INTERNAL"},
+			Overrides:       []*FailureOverride{{HttpRoute: "/health", HttpStatusCodes: []string{".*"}}},
+		},
+	}
+	err := cfg.SetFailureCriteria(&lotusv1beta1.LotusFailureCriteria{
+		HTTPStatusCodes: []string{"5..", "429"},
+		Overrides:       []lotusv1beta1.LotusFailureOverride{{HTTPRoute: "/users/*", HTTPStatusCodes: []string{"404"}}},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, &FailureCriteria{
+		HttpStatusCodes: []string{"5..", "429"},
+		GrpcCodes:       []string{"INTERNAL"},
+		Overrides: []*FailureOverride{
+			{HttpRoute: "/users/*", HttpStatusCodes: []string{"404"}},
+			{HttpRoute: "/health", HttpStatusCodes: []string{".*"}},
+		},
+	}, cfg.FailureCriteria)
+
+	err = cfg.SetFailureCriteria(&lotusv1beta1.LotusFailureCriteria{
+		Overrides: []lotusv1beta1.LotusFailureOverride{{HTTPRoute: "/users/*"}},
+	})
+	assert.Error(t, err)
+}
@@ -0,0 +1,245 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lotusload/lotus/pkg/app/lotus/model"
+	"github.com/lotusload/lotus/pkg/metrics/grpcmetrics"
+	"github.com/lotusload/lotus/pkg/metrics/httpmetrics"
+)
+
+const (
+	MetricHTTPLatency   = "http.latency"
+	MetricHTTPErrorRate = "http.errorRate"
+	MetricHTTPRPS       = "http.rps"
+	MetricGRPCLatency   = "grpc.latency"
+	MetricGRPCErrorRate = "grpc.errorRate"
+	MetricGRPCRPS       = "grpc.rps"
+	defaultQuantile     = 0.99
+	thresholdRateWindow = "1m"
+	metricsNamespace    = "lotus"
+)
+
+var (
+	httpLatencyMetric   = prometheusMetricName(httpmetrics.ClientRoundtripLatencyDistribution.Name)
+	httpCompletedMetric = prometheusMetricName(httpmetrics.ClientCompletedCount.Name)
+	grpcLatencyMetric   = prometheusMetricName(grpcmetrics.ClientRoundtripLatencyView.Name)
+	grpcCompletedMetric = prometheusMetricName(grpcmetrics.ClientCompletedRPCsView.Name)
+
+	// negatedOps maps the expected relation to the condition firing the check.
+	negatedOps = map[string]string{
+		"<":  ">=",
+		"<=": ">",
+		">":  "<=",
+		">=": "<",
+		"==": "!=",
+		"!=": "==",
+	}
+)
+
+// prometheusMetricName returns the name of the given view exported by pkg/metrics.
+func prometheusMetricName(view string) string {
+	return fmt.Sprintf("%s_%s", metricsNamespace, strings.Replace(view, "/", "_", -1))
+}
+
+// resolveThreshold translates the threshold of the check into its expr.
+// The threshold fields are cleared, so that the check can be marshaled and loaded again.
+// The expr only selects the metrics of the given test, or of all tests when the test ID is empty.
+// The error rates count the failures decided by the given criteria.
+func (c *Check) resolveThreshold(testID string, fc *FailureCriteria) error {
+	if c.Metric == "" {
+		if c.Expr == "" {
+			return fmt.Errorf("check %s: either expr or metric is required", c.Name)
+		}
+		return nil
+	}
+	if c.Expr != "" {
+		return fmt.Errorf("check %s: expr and metric cannot be specified together", c.Name)
+	}
+	expr, err := thresholdExpr(c, testID, fc)
+	if err != nil {
+		return fmt.Errorf("check %s: %v", c.Name, err)
+	}
+	c.Expr = expr
+	c.Metric, c.Quantile, c.Host, c.Route, c.Method, c.Op, c.Value = "", 0, "", "", "", "", ""
+	return nil
+}
+
+func thresholdExpr(c *Check, testID string, fc *FailureCriteria) (string, error) {
+	op, ok := negatedOps[c.Op]
+	if !ok {
+		return "", fmt.Errorf("unsupported op: %q", c.Op)
+	}
+	var (
+		matchers map[string]string
+		expr     string
+	)
+	switch c.Metric {
+	case MetricHTTPLatency, MetricHTTPErrorRate, MetricHTTPRPS:
+		matchers = map[string]string{
+			model.TestIDLabel:                  testID,
+			httpmetrics.KeyClientHost.Name():   c.Host,
+			httpmetrics.KeyClientRoute.Name():  c.Route,
+			httpmetrics.KeyClientMethod.Name(): c.Method,
+		}
+	case MetricGRPCLatency, MetricGRPCErrorRate, MetricGRPCRPS:
+		if c.Host != "" || c.Route != "" {
+			return "", fmt.Errorf("host and route are not supported by %s", c.Metric)
+		}
+		matchers = map[string]string{
+			model.TestIDLabel:                  testID,
+			grpcmetrics.KeyClientMethod.Name(): c.Method,
+		}
+	default:
+		return "", fmt.Errorf("unsupported metric: %q", c.Metric)
+	}
+	if c.Quantile != 0 && c.Metric != MetricHTTPLatency && c.Metric != MetricGRPCLatency {
+		return "", fmt.Errorf("quantile is not supported by %s", c.Metric)
+	}
+	selector := labelSelector(matchers)
+
+	switch c.Metric {
+	case MetricHTTPLatency, MetricGRPCLatency:
+		metric := httpLatencyMetric
+		if c.Metric == MetricGRPCLatency {
+			metric = grpcLatencyMetric
+		}
+		quantile := c.Quantile
+		if quantile == 0 {
+			quantile = defaultQuantile
+		}
+		if quantile <= 0 || quantile >= 1 {
+			return "", fmt.Errorf("quantile must be between 0 and 1: %g", quantile)
+		}
+		expr = fmt.Sprintf("histogram_quantile(%g, sum by (le) (rate(%s_bucket%s[%s])))",
+			quantile, metric, selector, thresholdRateWindow)
+	case MetricHTTPErrorRate:
+		expr = fmt.Sprintf("sum(%s) / sum(rate(%s%s[%s]))",
+			failureRate(httpCompletedMetric, matchers, fc.HTTPFailureMatchers()),
+			httpCompletedMetric, selector, thresholdRateWindow)
+	case MetricGRPCErrorRate:
+		expr = fmt.Sprintf("sum(%s) / sum(rate(%s%s[%s]))",
+			failureRate(grpcCompletedMetric, matchers, fc.GRPCFailureMatchers()),
+			grpcCompletedMetric, selector, thresholdRateWindow)
+	case MetricHTTPRPS:
+		expr = fmt.Sprintf("sum(rate(%s%s[%s]))", httpCompletedMetric, selector, thresholdRateWindow)
+	case MetricGRPCRPS:
+		expr = fmt.Sprintf("sum(rate(%s%s[%s]))", grpcCompletedMetric, selector, thresholdRateWindow)
+	}
+
+	value, err := thresholdValue(c.Metric, c.Value)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s %s %s", expr, op, strconv.FormatFloat(value, 'g', -1, 64)), nil
+}
+
+// thresholdValue parses the value in the unit of the metric:
+// milliseconds for latencies, a ratio for error rates and requests per second for rps.
+func thresholdValue(metric, value string) (float64, error) {
+	if value == "" {
+		return 0, fmt.Errorf("value is required")
+	}
+	switch metric {
+	case MetricHTTPLatency, MetricGRPCLatency:
+		if d, err := time.ParseDuration(value); err == nil {
+			return float64(d) / float64(time.Millisecond), nil
+		}
+	case MetricHTTPErrorRate, MetricGRPCErrorRate:
+		if strings.HasSuffix(value, "%") {
+			v, err := strconv.ParseFloat(strings.TrimSuffix(value, "%"), 64)
+			if err != nil {
+				return 0, fmt.Errorf("invalid value: %q", value)
+			}
+			return v / 100, nil
+		}
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value: %q", value)
+	}
+	return v, nil
+}
+
+// failureRate returns the rate of the failures among the requests selected by the given patterns of labels.
+func failureRate(metric string, matchers map[string]string, failures []string) string {
+	selector := strings.Join(labelMatchers(matchers), ",")
+	return UnionOf(failures, func(failure string) string {
+		if selector != "" {
+			failure = fmt.Sprintf("%s,%s", selector, failure)
+		}
+		return fmt.Sprintf("rate(%s{%s}[%s])", metric, failure, thresholdRateWindow)
+	})
+}
+
+type labelMatcher struct {
+	op    string
+	value string
+}
+
+// labelSelector builds a PromQL label selector from the glob patterns of labels.
+// A value may also start with an explicit "=~" or "!~" followed by a regular expression.
+func labelSelector(matchers map[string]string) string {
+	parts := labelMatchers(matchers)
+	if len(parts) == 0 {
+		return ""
+	}
+	return fmt.Sprintf("{%s}", strings.Join(parts, ","))
+}
+
+func labelMatchers(matchers map[string]string) []string {
+	labels := make([]string, 0, len(matchers))
+	for label, pattern := range matchers {
+		if pattern != "" {
+			labels = append(labels, label)
+		}
+	}
+	sort.Strings(labels)
+	parts := make([]string, 0, len(labels))
+	for _, label := range labels {
+		parts = append(parts, formatMatcher(label, globMatcher(matchers[label])))
+	}
+	return parts
+}
+
+func globMatcher(pattern string) labelMatcher {
+	for _, op := range []string{"=~", "!~"} {
+		if strings.HasPrefix(pattern, op) {
+			return labelMatcher{op: op, value: strings.TrimPrefix(pattern, op)}
+		}
+	}
+	if !strings.Contains(pattern, "*") {
+		return labelMatcher{op: "=", value: pattern}
+	}
+	return labelMatcher{op: "=~", value: globRegexp(pattern)}
+}
+
+// globRegexp translates the glob pattern whose only wildcard is "*" into a regular expression.
+func globRegexp(pattern string) string {
+	return strings.Replace(regexp.QuoteMeta(pattern), `\*`, ".*", -1)
+}
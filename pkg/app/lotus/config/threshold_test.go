@@ -0,0 +1,111 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolveThreshold(t *testing.T) {
+	testcases := []struct {
+		Check    Check
+		TestID   string
+		Criteria *FailureCriteria
+		Expected string
+		Err      bool
+	}{
+		{
+			Check:    Check{Name: "p99", Metric: MetricHTTPLatency, Route: "/api/*", Op: "<", Value: "300ms"},
+			Expected: `histogram_quantile(0.99, sum by (le) (rate(lotus_http_client_roundtrip_latency_bucket{http_client_route=~"/api/.*"}[1m]))) >= 300`,
+		},
+		{
+			Check:    Check{Name: "p90", Metric: MetricGRPCLatency, Quantile: 0.9, Op: "<=", Value: "1.5s"},
+			Expected: `histogram_quantile(0.9, sum by (le) (rate(lotus_grpc_client_roundtrip_latency_bucket[1m]))) > 1500`,
+		},
+		{
+			Check:    Check{Name: "errors", Metric: MetricGRPCErrorRate, Method: "helloworld.Greeter/SayHello", Op: "<", Value: "1%"},
+			Expected: `sum(rate(lotus_grpc_client_completed_rpcs{grpc_client_method="helloworld.Greeter/SayHello",grpc_client_status!~"OK|NOT_FOUND"}[1m])) / sum(rate(lotus_grpc_client_completed_rpcs{grpc_client_method="helloworld.Greeter/SayHello"}[1m])) >= 0.01`,
+		},
+		{
+			Check:    Check{Name: "errors", Metric: MetricHTTPErrorRate, Host: "api.example.com", Op: "<", Value: "0.05"},
+			Expected: `sum(rate(lotus_http_client_completed_count{http_client_host="api.example.com",http_client_status=~"5.."}[1m])) / sum(rate(lotus_http_client_completed_count{http_client_host="api.example.com"}[1m])) >= 0.05`,
+		},
+		{
+			Check: Check{Name: "errors", Metric: MetricHTTPErrorRate, Method: "GET", Op: "<", Value: "1%"},
+			Criteria: &FailureCriteria{
+				HttpStatusCodes: []string{"5..", "429"},
+				Overrides:       []*FailureOverride{{HttpRoute: "/users/*", HttpStatusCodes: []string{"404"}}},
+			},
+			Expected: `sum(rate(lotus_http_client_completed_count{http_client_method="GET",http_client_route=~"/users/.*",http_client_status=~"404"}[1m]) or rate(lotus_http_client_completed_count{http_client_method="GET",http_client_route!~"/users/.*",http_client_status=~"5..|429"}[1m])) / sum(rate(lotus_http_client_completed_count{http_client_method="GET"}[1m])) >= 0.01`,
+		},
+		{
+			Check:    Check{Name: "rps", Metric: MetricHTTPRPS, Method: "GET", Op: ">", Value: "100"},
+			Expected: `sum(rate(lotus_http_client_completed_count{http_client_method="GET"}[1m])) <= 100`,
+		},
+		{
+			Check:    Check{Name: "test", Metric: MetricGRPCErrorRate, Op: "<", Value: "1%"},
+			TestID:   "c7b3e1f0",
+			Expected: `sum(rate(lotus_grpc_client_completed_rpcs{lotus_test_id="c7b3e1f0",grpc_client_status!~"OK|NOT_FOUND"}[1m])) / sum(rate(lotus_grpc_client_completed_rpcs{lotus_test_id="c7b3e1f0"}[1m])) >= 0.01`,
+		},
+		{
+			Check:    Check{Name: "expr", Expr: "up == 0"},
+			Expected: "up == 0",
+		},
+		{
+			Check: Check{Name: "empty"},
+			Err:   true,
+		},
+		{
+			Check: Check{Name: "both", Expr: "up == 0", Metric: MetricHTTPRPS, Op: ">", Value: "1"},
+			Err:   true,
+		},
+		{
+			Check: Check{Name: "unknown", Metric: "http.unknown", Op: ">", Value: "1"},
+			Err:   true,
+		},
+		{
+			Check: Check{Name: "op", Metric: MetricHTTPRPS, Op: "=>", Value: "1"},
+			Err:   true,
+		},
+		{
+			Check: Check{Name: "route", Metric: MetricGRPCRPS, Route: "/api", Op: ">", Value: "1"},
+			Err:   true,
+		},
+		{
+			Check: Check{Name: "value", Metric: MetricHTTPLatency, Op: "<", Value: "fast"},
+			Err:   true,
+		},
+	}
+	for _, tc := range testcases {
+		check := tc.Check
+		err := check.resolveThreshold(tc.TestID, tc.Criteria)
+		if tc.Err {
+			assert.Error(t, err, tc.Check.Name)
+			continue
+		}
+		require.NoError(t, err, tc.Check.Name)
+		assert.Equal(t, tc.Expected, check.Expr)
+		assert.Empty(t, check.Metric)
+	}
+}
@@ -22,11 +22,17 @@ package controller
 
 import (
 	"context"
+	"crypto/rand"
+	"encoding/hex"
 	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
 	"time"
 
 	"go.uber.org/zap"
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -60,8 +66,11 @@ type Controller struct {
 	lotusesLister listers.LotusLister
 	lotusesSynced cache.InformerSynced
 
-	workqueue workqueue.RateLimitingInterface
-	recorder  record.EventRecorder
+	workqueue        workqueue.RateLimitingInterface
+	recorder         record.EventRecorder
+	httpClient       *http.Client
+	started          *startedLotuses
+	preflightRetries *preflightRetries
 
 	namespace                string
 	release                  string
@@ -94,13 +103,16 @@ func NewController(
 	})
 
 	controller := &Controller{
-		kubeClient:               kubeclient.New(kubeclientset, jobInformer.Lister()),
+		kubeClient:               kubeclient.New(kubeclientset, jobInformer.Lister(), logger),
 		lotusclientset:           lotusclientset,
 		jobsSynced:               jobInformer.Informer().HasSynced,
 		lotusesLister:            lotusInformer.Lister(),
 		lotusesSynced:            lotusInformer.Informer().HasSynced,
 		workqueue:                workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "Lotuses"),
 		recorder:                 recorder,
+		httpClient:               &http.Client{Timeout: 10 * time.Second},
+		started:                  &startedLotuses{keys: make(map[string]struct{})},
+		preflightRetries:         newPreflightRetries(),
 		namespace:                namespace,
 		release:                  release,
 		prometheusServiceAccount: prometheusServiceAccount,
@@ -111,6 +123,15 @@ func NewController(
 		AddFunc: controller.enqueueLotus,
 		UpdateFunc: func(old, new interface{}) {
 			controller.enqueueLotus(new)
+			// A finished Lotus may allow a queued one to start.
+			oldLotus, oldOK := old.(*lotusv1beta1.Lotus)
+			newLotus, newOK := new.(*lotusv1beta1.Lotus)
+			if oldOK && newOK && isActive(oldLotus.Status.Phase) && !isActive(newLotus.Status.Phase) {
+				controller.enqueuePendingLotuses()
+			}
+		},
+		DeleteFunc: func(obj interface{}) {
+			controller.enqueuePendingLotuses()
 		},
 	})
 	jobInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
@@ -206,9 +227,9 @@ func (c *Controller) syncHandler(key string) error {
 
 	switch lotus.Status.Phase {
 	case lotusv1beta1.LotusInit:
-		return c.updateLotusStatus(lotus, lotusv1beta1.LotusPending)
+		return c.syncInitLotus(lotus)
 	case lotusv1beta1.LotusPending:
-		return c.updateLotusStatus(lotus, lotusv1beta1.LotusPreparing)
+		return c.syncPendingLotus(lotus)
 	case lotusv1beta1.LotusPreparing:
 		if lotus.Spec.Preparer == nil {
 			return c.toRunningPhase(lotus)
@@ -227,14 +248,48 @@ func (c *Controller) syncHandler(key string) error {
 		}
 		return c.syncFailureCleaningLotus(lotus)
 	case lotusv1beta1.LotusSucceeded:
-		return nil
+		return c.syncFinishedLotus(lotus)
 	case lotusv1beta1.LotusFailed:
-		return nil
+		return c.syncFinishedLotus(lotus)
 	}
 	c.logger.Warn("unexpected lotus phase", zap.String("phase", string(lotus.Status.Phase)))
 	return nil
 }
 
+func (c *Controller) syncInitLotus(lotus *lotusv1beta1.Lotus) error {
+	lotusCopy := lotus.DeepCopy()
+	err := c.resolveTemplates(lotusCopy)
+	if _, ok := err.(invalidTemplateError); ok {
+		c.logger.Info("failed to resolve templates", zap.String("lotus", lotus.Name), zap.Error(err))
+		c.recorder.Event(lotus, corev1.EventTypeWarning, "TemplateResolutionFailed", err.Error())
+		lotus = lotus.DeepCopy()
+		lotus.Status.Reason = fmt.Sprintf("TemplateResolutionFailed: %v", err)
+		return c.updateLotusStatus(lotus, lotusv1beta1.LotusFailed)
+	}
+	if err != nil {
+		return err
+	}
+	cfg := &config.Config{}
+	if err := cfg.SetFailureCriteria(lotusCopy.Spec.FailureCriteria); err != nil {
+		c.logger.Info("invalid failure criteria", zap.String("lotus", lotus.Name), zap.Error(err))
+		c.recorder.Event(lotus, corev1.EventTypeWarning, "InvalidFailureCriteria", err.Error())
+		lotus = lotus.DeepCopy()
+		lotus.Status.Reason = fmt.Sprintf("InvalidFailureCriteria: %v", err)
+		return c.updateLotusStatus(lotus, lotusv1beta1.LotusFailed)
+	}
+	if err := cfg.AddChecks(string(lotusCopy.UID), lotusCopy.Spec.Checks...); err != nil {
+		c.logger.Info("invalid checks", zap.String("lotus", lotus.Name), zap.Error(err))
+		c.recorder.Event(lotus, corev1.EventTypeWarning, "InvalidChecks", err.Error())
+		lotus = lotus.DeepCopy()
+		lotus.Status.Reason = fmt.Sprintf("InvalidChecks: %v", err)
+		return c.updateLotusStatus(lotus, lotusv1beta1.LotusFailed)
+	}
+	if a := rolloutAnnotation(lotusCopy); a != nil {
+		lotusCopy.Status.Annotations = append(lotusCopy.Status.Annotations, *a)
+	}
+	return c.updateLotusStatus(lotusCopy, lotusv1beta1.LotusPending)
+}
+
 func (c *Controller) syncPreparingLotus(lotus *lotusv1beta1.Lotus) error {
 	factory := resource.NewFactory(lotus, c.configFile)
 	jobName := factory.PreparerJobName()
@@ -243,9 +298,17 @@ func (c *Controller) syncPreparingLotus(lotus *lotusv1beta1.Lotus) error {
 		return err
 	}
 	if job.Status.Failed > 0 {
+		// The cleaner may need the data of the fixtures created before the failure.
+		if err := c.storeHandoff(lotus, job); err != nil {
+			c.logger.Warn("failed to store handoff data of failed preparer", zap.Error(err))
+		}
 		return c.updateLotusStatus(lotus, lotusv1beta1.LotusFailureCleaning)
 	}
 	if job.Status.Succeeded > 0 {
+		if err := c.storeHandoff(lotus, job); err != nil {
+			c.logger.Error("failed to store handoff data", zap.Error(err))
+			return err
+		}
 		return c.toRunningPhase(lotus)
 	}
 	c.logger.Info("preparer job is still running", zap.String("name", jobName))
@@ -259,30 +322,69 @@ func (c *Controller) toRunningPhase(lotus *lotusv1beta1.Lotus) error {
 	if err := c.ensureWorkerResources(lotus); err != nil {
 		return err
 	}
+	if err := c.ensureMonitorResources(lotus); err != nil {
+		return err
+	}
+	return c.updateLotusStatus(lotus, lotusv1beta1.LotusRunning)
+}
+
+func (c *Controller) ensureMonitorResources(lotus *lotusv1beta1.Lotus) error {
 	factory := resource.NewFactory(lotus, c.configFile)
 	name := factory.MonitorJobName()
-	if _, err := c.kubeClient.EnsureConfigMap(name, lotus.Namespace, factory.NewMonitorConfigMap); err != nil {
+	configMap, err := factory.NewMonitorConfigMap()
+	if err != nil {
 		return err
 	}
-	return c.updateLotusStatus(lotus, lotusv1beta1.LotusRunning)
+	if _, err := c.kubeClient.ApplyConfigMap(name, lotus.Namespace, configMap); err != nil {
+		return err
+	}
+	service, err := factory.NewMonitorService()
+	if err != nil {
+		return err
+	}
+	_, err = c.kubeClient.ApplyService(name, lotus.Namespace, service)
+	return err
 }
 
 func (c *Controller) syncRunningLotus(lotus *lotusv1beta1.Lotus) error {
 	factory := resource.NewFactory(lotus, c.configFile)
 	jobName := factory.MonitorJobName()
-	job, err := c.kubeClient.EnsureJob(jobName, lotus.Namespace, factory.NewMonitorJob)
+	monitorJobFactory := func() (*batchv1.Job, error) {
+		// The monitor reads the stop token at startup.
+		if _, err := c.ensureMonitorStopToken(lotus); err != nil {
+			return nil, err
+		}
+		return factory.NewMonitorJob()
+	}
+	job, err := c.kubeClient.EnsureJob(jobName, lotus.Namespace, monitorJobFactory)
 	if err != nil {
 		return err
 	}
 	if job.Status.Succeeded == 0 && job.Status.Failed == 0 {
+		if err := c.ensurePrometheusResources(lotus); err != nil {
+			c.logger.Error("failed to sync prometheus resources", zap.Error(err))
+			return err
+		}
+		if err := c.ensureMonitorResources(lotus); err != nil {
+			c.logger.Error("failed to sync monitor resources", zap.Error(err))
+			return err
+		}
+		lotus = c.publishProgress(lotus)
+		if lotus.Spec.Worker.Mode == lotusv1beta1.LotusWorkerJob {
+			return c.syncWorkerJobs(lotus)
+		}
+		if err := c.syncWorkerResources(lotus); err != nil {
+			c.logger.Error("failed to sync worker resources", zap.Error(err))
+			return err
+		}
+		if sc := lotus.Spec.StopConditions; sc != nil && sc.WorkersTerminated {
+			return c.syncWorkerPods(lotus)
+		}
 		c.logger.Info("monitor job is still running", zap.String("name", jobName))
 		return nil
 	}
-	// Scale down or Delete worker deployment.
-	workerName := factory.WorkerName()
-	err = c.kubeClient.DeleteDeployment(workerName, lotus.Namespace)
-	if err != nil {
-		c.logger.Error("failed to delete worker deployment", zap.Error(err))
+	if err := c.deleteWorkerResources(lotus); err != nil {
+		c.logger.Error("failed to delete worker resources", zap.Error(err))
 		return err
 	}
 	if job.Status.Failed > 0 {
@@ -320,20 +422,169 @@ func (c *Controller) syncFailureCleaningLotus(lotus *lotusv1beta1.Lotus) error {
 	return nil
 }
 
+// syncWorkerJobs stops the monitor as soon as all shards of the worker job have completed
+// or one of them has failed, so that the Running phase does not have to wait
+// until runTime expires.
+func (c *Controller) syncWorkerJobs(lotus *lotusv1beta1.Lotus) error {
+	job, err := c.ensureWorkerJob(lotus)
+	if err != nil {
+		return err
+	}
+	if job.Status.Failed > 0 {
+		return c.stopMonitor(lotus, fmt.Sprintf("worker job %s failed", job.Name), true)
+	}
+	total := resource.NewFactory(lotus, c.configFile).WorkerReplicas()
+	if job.Status.Succeeded < total {
+		c.logger.Info("worker jobs are still running",
+			zap.String("lotus", lotus.Name),
+			zap.Int32("succeeded", job.Status.Succeeded),
+			zap.Int32("total", total))
+		return nil
+	}
+	return c.stopMonitor(lotus, "all worker jobs have completed", false)
+}
+
+// syncWorkerPods stops the monitor as soon as every container of the worker pods
+// has exited successfully. It is used by the workersTerminated stop condition.
+func (c *Controller) syncWorkerPods(lotus *lotusv1beta1.Lotus) error {
+	factory := resource.NewFactory(lotus, c.configFile)
+	pods, err := c.kubeClient.ListPods(lotus.Namespace, factory.WorkerSelector())
+	if err != nil {
+		c.logger.Error("failed to list worker pods", zap.Error(err))
+		return err
+	}
+	if len(pods) == 0 || !workerPodsTerminated(pods) {
+		c.logger.Info("worker pods are still running",
+			zap.String("lotus", lotus.Name),
+			zap.Int("pods", len(pods)))
+		return nil
+	}
+	return c.stopMonitor(lotus, "all worker pods have terminated", false)
+}
+
+// workerPodsTerminated reports whether every container of the given pods has exited with code 0.
+// Only the current state is taken into account, since a container which has been
+// restarted after exiting, e.g. by the worker Deployment, is running again.
+func workerPodsTerminated(pods []corev1.Pod) bool {
+	for _, pod := range pods {
+		if len(pod.Status.ContainerStatuses) == 0 {
+			return false
+		}
+		for _, status := range pod.Status.ContainerStatuses {
+			terminated := status.State.Terminated
+			if terminated == nil || terminated.ExitCode != 0 {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+// stopMonitor asks the monitor to finish the check loop and report the result.
+// The reason is recorded in the result, and used as the failure reason when failed is true.
+func (c *Controller) stopMonitor(lotus *lotusv1beta1.Lotus, reason string, failed bool) error {
+	factory := resource.NewFactory(lotus, c.configFile)
+	token, err := c.ensureMonitorStopToken(lotus)
+	if err != nil {
+		return err
+	}
+	values := url.Values{}
+	if failed {
+		values.Set("error", reason)
+	} else {
+		values.Set("reason", reason)
+	}
+	req, err := http.NewRequest(http.MethodPost, factory.MonitorAddress()+"/stop", strings.NewReader(values.Encode()))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Authorization", "Bearer "+token)
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		c.logger.Error("failed to stop monitor", zap.Error(err))
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("unexpected status code from monitor: %d", resp.StatusCode)
+	}
+	c.logger.Info("requested monitor to stop",
+		zap.String("lotus", lotus.Name),
+		zap.String("reason", reason))
+	return nil
+}
+
+// ensureMonitorStopToken returns the token which authorizes the stop requests to the monitor.
+// The token is generated and stored in the monitor Secret at the first call.
+func (c *Controller) ensureMonitorStopToken(lotus *lotusv1beta1.Lotus) (string, error) {
+	factory := resource.NewFactory(lotus, c.configFile)
+	name := factory.MonitorJobName()
+	secret, err := c.kubeClient.GetSecret(name, lotus.Namespace)
+	if err == nil {
+		return string(secret.Data[resource.MonitorStopTokenKey]), nil
+	}
+	if !errors.IsNotFound(err) {
+		return "", err
+	}
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	token := hex.EncodeToString(buf)
+	secret, err = factory.NewMonitorSecret([]byte(token))
+	if err != nil {
+		return "", err
+	}
+	if _, err := c.kubeClient.ApplySecret(name, lotus.Namespace, secret); err != nil {
+		return "", err
+	}
+	return token, nil
+}
+
 func (c *Controller) ensureWorkerResources(lotus *lotusv1beta1.Lotus) error {
 	factory := resource.NewFactory(lotus, c.configFile)
 	name := factory.WorkerName()
-	if _, err := c.kubeClient.EnsureService(name, lotus.Namespace, factory.NewWorkerService); err != nil {
+	service, err := factory.NewWorkerService()
+	if err != nil {
+		return err
+	}
+	if _, err := c.kubeClient.ApplyService(name, lotus.Namespace, service); err != nil {
+		return err
+	}
+	if lotus.Spec.Worker.Mode == lotusv1beta1.LotusWorkerJob {
+		_, err := c.ensureWorkerJob(lotus)
+		return err
+	}
+	deployment, err := factory.NewWorkerDeployment()
+	if err != nil {
 		return err
 	}
-	_, err := c.kubeClient.EnsureDeployment(name, lotus.Namespace, factory.NewWorkerDeployment)
+	_, err = c.kubeClient.ApplyDeployment(name, lotus.Namespace, deployment)
 	return err
 }
 
+func (c *Controller) ensureWorkerJob(lotus *lotusv1beta1.Lotus) (*batchv1.Job, error) {
+	factory := resource.NewFactory(lotus, c.configFile)
+	return c.kubeClient.EnsureIndexedJob(factory.WorkerName(), lotus.Namespace, factory.NewWorkerJob)
+}
+
+func (c *Controller) deleteWorkerResources(lotus *lotusv1beta1.Lotus) error {
+	factory := resource.NewFactory(lotus, c.configFile)
+	if lotus.Spec.Worker.Mode != lotusv1beta1.LotusWorkerJob {
+		return c.kubeClient.DeleteDeployment(factory.WorkerName(), lotus.Namespace)
+	}
+	return c.kubeClient.DeleteJob(factory.WorkerName(), lotus.Namespace)
+}
+
 func (c *Controller) ensurePrometheusResources(lotus *lotusv1beta1.Lotus) error {
 	factory := resource.NewFactory(lotus, c.configFile)
 	name := factory.PrometheusName()
-	if _, err := c.kubeClient.EnsureConfigMap(name, lotus.Namespace, factory.NewPrometheusConfigMap); err != nil {
+	configMap, err := factory.NewPrometheusConfigMap()
+	if err != nil {
+		return err
+	}
+	if _, err := c.kubeClient.ApplyConfigMap(name, lotus.Namespace, configMap); err != nil {
 		return err
 	}
 	podFactory := func() (*corev1.Pod, error) {
@@ -342,7 +593,11 @@ func (c *Controller) ensurePrometheusResources(lotus *lotusv1beta1.Lotus) error
 	if _, err := c.kubeClient.EnsurePod(name, lotus.Namespace, podFactory); err != nil {
 		return err
 	}
-	_, err := c.kubeClient.EnsureService(name, lotus.Namespace, factory.NewPrometheusService)
+	service, err := factory.NewPrometheusService()
+	if err != nil {
+		return err
+	}
+	_, err = c.kubeClient.ApplyService(name, lotus.Namespace, service)
 	return err
 }
 
@@ -446,7 +701,7 @@ func (c *Controller) ensureStaticResources() error {
 	if err != nil {
 		return err
 	}
-	if err := c.kubeClient.ApplyService(f.ThanosPeerName(), c.namespace, thanosPeerService); err != nil {
+	if _, err := c.kubeClient.ApplyService(f.ThanosPeerName(), c.namespace, thanosPeerService); err != nil {
 		return err
 	}
 
@@ -459,14 +714,14 @@ func (c *Controller) ensureStaticResources() error {
 		if err != nil {
 			return err
 		}
-		if err := c.kubeClient.ApplySecret(f.TimeSeriesStoreConfigSecretName(), c.namespace, timeSeriesStoreSecret); err != nil {
+		if _, err := c.kubeClient.ApplySecret(f.TimeSeriesStoreConfigSecretName(), c.namespace, timeSeriesStoreSecret); err != nil {
 			return err
 		}
 		thanosStore, err := f.NewThanosStoreStatefulSet()
 		if err != nil {
 			return err
 		}
-		if err := c.kubeClient.ApplyStatefulSet(f.ThanosStoreName(), c.namespace, thanosStore); err != nil {
+		if _, err := c.kubeClient.ApplyStatefulSet(f.ThanosStoreName(), c.namespace, thanosStore); err != nil {
 			return err
 		}
 	}
@@ -475,12 +730,13 @@ func (c *Controller) ensureStaticResources() error {
 	if err != nil {
 		return err
 	}
-	if err := c.kubeClient.ApplyDeployment(f.ThanosQueryName(), c.namespace, thanosQueryDeployment); err != nil {
+	if _, err := c.kubeClient.ApplyDeployment(f.ThanosQueryName(), c.namespace, thanosQueryDeployment); err != nil {
 		return err
 	}
 	thanosQueryService, err := f.NewThanosQueryService()
 	if err != nil {
 		return err
 	}
-	return c.kubeClient.ApplyService(f.ThanosQueryName(), c.namespace, thanosQueryService)
+	_, err = c.kubeClient.ApplyService(f.ThanosQueryName(), c.namespace, thanosQueryService)
+	return err
 }
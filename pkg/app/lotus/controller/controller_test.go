@@ -18,5 +18,395 @@
 // OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
 // SOFTWARE.
 
-
 package controller
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	lotusv1beta1 "github.com/lotusload/lotus/pkg/app/lotus/apis/lotus/v1beta1"
+	"github.com/lotusload/lotus/pkg/app/lotus/config"
+	"github.com/lotusload/lotus/pkg/app/lotus/model"
+	"github.com/lotusload/lotus/pkg/app/lotus/resource"
+)
+
+func TestDeploymentChanges(t *testing.T) {
+	newDeployment := func(replicas int32, hash string) *appsv1.Deployment {
+		d := &appsv1.Deployment{
+			Spec: appsv1.DeploymentSpec{
+				Replicas: &replicas,
+			},
+		}
+		if hash != "" {
+			d.ObjectMeta = metav1.ObjectMeta{
+				Annotations: map[string]string{
+					resource.WorkerTemplateHashAnnotation: hash,
+				},
+			}
+		}
+		return d
+	}
+	testcases := []struct {
+		Current  *appsv1.Deployment
+		Desired  *appsv1.Deployment
+		Expected []string
+	}{
+		{
+			Current:  newDeployment(2, "a"),
+			Desired:  newDeployment(2, "a"),
+			Expected: []string{},
+		},
+		{
+			Current:  newDeployment(2, "a"),
+			Desired:  newDeployment(5, "b"),
+			Expected: []string{"replicas 2 -> 5", "pod template changed"},
+		},
+		{
+			Current:  newDeployment(2, ""),
+			Desired:  newDeployment(2, "b"),
+			Expected: []string{},
+		},
+	}
+	for _, tc := range testcases {
+		assert.Equal(t, tc.Expected, deploymentChanges(tc.Current, tc.Desired))
+	}
+}
+
+func TestShouldDeleteResources(t *testing.T) {
+	testcases := []struct {
+		Policy   lotusv1beta1.LotusRetentionPolicy
+		Phase    lotusv1beta1.LotusPhase
+		Expected bool
+	}{
+		{
+			Policy:   "",
+			Phase:    lotusv1beta1.LotusSucceeded,
+			Expected: false,
+		},
+		{
+			Policy:   lotusv1beta1.LotusRetentionKeep,
+			Phase:    lotusv1beta1.LotusFailed,
+			Expected: false,
+		},
+		{
+			Policy:   lotusv1beta1.LotusRetentionDeleteOnSuccess,
+			Phase:    lotusv1beta1.LotusSucceeded,
+			Expected: true,
+		},
+		{
+			Policy:   lotusv1beta1.LotusRetentionDeleteOnSuccess,
+			Phase:    lotusv1beta1.LotusFailed,
+			Expected: false,
+		},
+		{
+			Policy:   lotusv1beta1.LotusRetentionDeleteAlways,
+			Phase:    lotusv1beta1.LotusFailed,
+			Expected: true,
+		},
+	}
+	for _, tc := range testcases {
+		lotus := &lotusv1beta1.Lotus{
+			Spec:   lotusv1beta1.LotusSpec{RetentionPolicy: tc.Policy},
+			Status: lotusv1beta1.LotusStatus{Phase: tc.Phase},
+		}
+		assert.Equal(t, tc.Expected, shouldDeleteResources(lotus))
+	}
+}
+
+func TestCompletionTime(t *testing.T) {
+	now := time.Now()
+	preparer := metav1.NewTime(now.Add(-time.Hour))
+	worker := metav1.NewTime(now)
+	lotus := &lotusv1beta1.Lotus{}
+	assert.Nil(t, completionTime(lotus))
+
+	lotus.Status.PreparerCompletionTime = &preparer
+	assert.Equal(t, &preparer, completionTime(lotus))
+
+	lotus.Status.WorkerCompletionTime = &worker
+	assert.Equal(t, &worker, completionTime(lotus))
+}
+
+func TestQueuePositions(t *testing.T) {
+	now := time.Now()
+	newLotus := func(name, namespace, target string, phase lotusv1beta1.LotusPhase, priority int32, age time.Duration) *lotusv1beta1.Lotus {
+		return &lotusv1beta1.Lotus{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:              name,
+				Namespace:         namespace,
+				Labels:            map[string]string{"target": target},
+				CreationTimestamp: metav1.NewTime(now.Add(-age)),
+			},
+			Spec:   lotusv1beta1.LotusSpec{Priority: priority},
+			Status: lotusv1beta1.LotusStatus{Phase: phase},
+		}
+	}
+	notStarted := func(string) bool { return false }
+	testcases := []struct {
+		Name     string
+		Lotuses  []*lotusv1beta1.Lotus
+		Started  func(string) bool
+		Limits   *config.Concurrency
+		Expected map[string]int32
+	}{
+		{
+			Name: "no limits",
+			Lotuses: []*lotusv1beta1.Lotus{
+				newLotus("a", "ns", "", lotusv1beta1.LotusRunning, 0, time.Hour),
+				newLotus("b", "ns", "", lotusv1beta1.LotusPending, 0, time.Minute),
+			},
+			Started:  notStarted,
+			Limits:   nil,
+			Expected: map[string]int32{},
+		},
+		{
+			Name: "global limit ordered by priority and creation time",
+			Lotuses: []*lotusv1beta1.Lotus{
+				newLotus("a", "ns", "", lotusv1beta1.LotusRunning, 0, time.Hour),
+				newLotus("b", "ns", "", lotusv1beta1.LotusPending, 0, 3*time.Minute),
+				newLotus("c", "ns", "", lotusv1beta1.LotusPending, 0, 2*time.Minute),
+				newLotus("d", "ns", "", lotusv1beta1.LotusPending, 10, time.Minute),
+				newLotus("e", "ns", "", lotusv1beta1.LotusSucceeded, 0, time.Hour),
+			},
+			Started:  notStarted,
+			Limits:   &config.Concurrency{MaxRunning: 2},
+			Expected: map[string]int32{"ns/b": 1, "ns/c": 2},
+		},
+		{
+			Name: "started lotuses are counted",
+			Lotuses: []*lotusv1beta1.Lotus{
+				newLotus("a", "ns", "", lotusv1beta1.LotusPending, 0, 2*time.Minute),
+				newLotus("b", "ns", "", lotusv1beta1.LotusPending, 0, time.Minute),
+			},
+			Started:  func(key string) bool { return key == "ns/a" },
+			Limits:   &config.Concurrency{MaxRunning: 1},
+			Expected: map[string]int32{"ns/b": 1},
+		},
+		{
+			Name: "per namespace and per target limits",
+			Lotuses: []*lotusv1beta1.Lotus{
+				newLotus("a", "ns1", "foo", lotusv1beta1.LotusRunning, 0, time.Hour),
+				newLotus("b", "ns1", "bar", lotusv1beta1.LotusPending, 0, 3*time.Minute),
+				newLotus("c", "ns2", "foo", lotusv1beta1.LotusPending, 0, 2*time.Minute),
+				newLotus("d", "ns2", "bar", lotusv1beta1.LotusPending, 0, time.Minute),
+			},
+			Started: notStarted,
+			Limits: &config.Concurrency{
+				MaxRunningPerNamespace: 1,
+				MaxRunningPerTarget:    1,
+				TargetLabel:            "target",
+			},
+			Expected: map[string]int32{"ns1/b": 1, "ns2/c": 2},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.Name, func(t *testing.T) {
+			positions := queuePositions(tc.Lotuses, tc.Started, tc.Limits)
+			assert.Equal(t, tc.Expected, positions)
+		})
+	}
+}
+
+func TestCheckQuotas(t *testing.T) {
+	worker := &lotusv1beta1.LotusSpecWorker{
+		Containers: []corev1.Container{
+			{
+				Name: "worker",
+				Resources: corev1.ResourceRequirements{
+					Requests: corev1.ResourceList{
+						corev1.ResourceCPU: apiresource.MustParse("500m"),
+					},
+				},
+			},
+		},
+	}
+	required := workerResourceRequirements(worker, 4)
+	cpu := required[corev1.ResourceRequestsCPU]
+	assert.Equal(t, "2", cpu.String())
+
+	newQuota := func(hard, used string) corev1.ResourceQuota {
+		return corev1.ResourceQuota{
+			ObjectMeta: metav1.ObjectMeta{Name: "compute"},
+			Status: corev1.ResourceQuotaStatus{
+				Hard: corev1.ResourceList{corev1.ResourceRequestsCPU: apiresource.MustParse(hard)},
+				Used: corev1.ResourceList{corev1.ResourceRequestsCPU: apiresource.MustParse(used)},
+			},
+		}
+	}
+	assert.NoError(t, checkQuotas(nil, required))
+	assert.NoError(t, checkQuotas([]corev1.ResourceQuota{newQuota("4", "2")}, required))
+	err := checkQuotas([]corev1.ResourceQuota{newQuota("4", "2500m")}, required)
+	assert.EqualError(t, err, "insufficient requests.cpu in resource quota compute: required 2, available 1500m")
+	// The scoped quotas may not apply to the worker pods.
+	scoped := newQuota("4", "2500m")
+	scoped.Spec.Scopes = []corev1.ResourceQuotaScope{corev1.ResourceQuotaScopeBestEffort}
+	assert.NoError(t, checkQuotas([]corev1.ResourceQuota{scoped}, required))
+}
+
+func TestParsePodSpec(t *testing.T) {
+	spec, err := parsePodSpec([]byte(`
+containers:
+  - name: worker
+    image: lotusload/lotus-example:v0.1.5
+    args: ["simple-http-scenario"]
+volumes:
+  - name: data
+    emptyDir: {}
+`))
+	assert.NoError(t, err)
+	assert.Equal(t, "worker", spec.Containers[0].Name)
+	assert.Equal(t, []string{"simple-http-scenario"}, spec.Containers[0].Args)
+	assert.Equal(t, "data", spec.Volumes[0].Name)
+
+	_, err = parsePodSpec([]byte(`volumes: []`))
+	assert.Error(t, err)
+}
+
+func TestPodTemplateImage(t *testing.T) {
+	template := &corev1.PodTemplateSpec{
+		Spec: corev1.PodSpec{
+			Containers: []corev1.Container{
+				corev1.Container{Name: "sidecar", Image: "envoy:v1"},
+				corev1.Container{Name: "app", Image: "app:v2"},
+			},
+		},
+	}
+	assert.Equal(t, "app:v2", podTemplateImage(template, "app"))
+	assert.Equal(t, "", podTemplateImage(template, "unknown"))
+	assert.Equal(t, "app=app:v2,sidecar=envoy:v1", podTemplateImage(template, ""))
+}
+
+func TestDeploymentRolledOut(t *testing.T) {
+	replicas := int32(2)
+	d := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Generation: 3},
+		Spec:       appsv1.DeploymentSpec{Replicas: &replicas},
+		Status: appsv1.DeploymentStatus{
+			ObservedGeneration: 3,
+			Replicas:           3,
+			UpdatedReplicas:    2,
+			AvailableReplicas:  2,
+		},
+	}
+	assert.False(t, deploymentRolledOut(d))
+	d.Status.Replicas = 2
+	assert.True(t, deploymentRolledOut(d))
+	d.Generation = 4
+	assert.False(t, deploymentRolledOut(d))
+}
+
+func TestNewTriggeredLotus(t *testing.T) {
+	trigger := &lotusv1beta1.LotusTrigger{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "lotus"},
+		Spec: lotusv1beta1.LotusTriggerSpec{
+			Target: lotusv1beta1.LotusTriggerTarget{
+				Kind:      lotusv1beta1.LotusTriggerDeployment,
+				Namespace: "default",
+			},
+		},
+	}
+	lotus := newTriggeredLotus(trigger, "Deployment/app", rollout{image: "app:v2", generation: 2})
+	assert.Equal(t, "lotus", lotus.Namespace)
+	assert.Equal(t, "app", lotus.Labels[TriggerLabel])
+	assert.Equal(t, "app:v2", lotus.Annotations[TriggerImageAnnotation])
+	assert.Equal(t, "default/Deployment/app", lotus.Annotations[TriggerTargetAnnotation])
+	assert.Empty(t, lotus.Status.Annotations)
+	assert.Equal(t, "testing app:v2 rolled out to default/Deployment/app", rolloutAnnotation(lotus).Text)
+	assert.Nil(t, rolloutAnnotation(&lotusv1beta1.Lotus{}))
+	assert.Equal(t, lotus.Name, newTriggeredLotus(trigger, "Deployment/app", rollout{image: "app:v2", generation: 2}).Name)
+	assert.NotEqual(t, lotus.Name, newTriggeredLotus(trigger, "Deployment/app", rollout{image: "app:v3", generation: 3}).Name)
+	// A rollback to the same image is tested again.
+	assert.NotEqual(t, lotus.Name, newTriggeredLotus(trigger, "Deployment/app", rollout{image: "app:v2", generation: 4}).Name)
+}
+
+func TestWorkerPodsTerminated(t *testing.T) {
+	terminated := func(code int32) corev1.ContainerState {
+		return corev1.ContainerState{
+			Terminated: &corev1.ContainerStateTerminated{ExitCode: code},
+		}
+	}
+	pods := []corev1.Pod{
+		corev1.Pod{
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{
+					corev1.ContainerStatus{Name: "worker", State: terminated(0)},
+				},
+			},
+		},
+		corev1.Pod{
+			Status: corev1.PodStatus{
+				ContainerStatuses: []corev1.ContainerStatus{
+					corev1.ContainerStatus{Name: "worker", LastTerminationState: terminated(0)},
+				},
+			},
+		},
+	}
+	// A restarted container is running again.
+	assert.False(t, workerPodsTerminated(pods))
+
+	pods[1].Status.ContainerStatuses[0].State = terminated(0)
+	assert.True(t, workerPodsTerminated(pods))
+
+	pods[1].Status.ContainerStatuses[0].State = terminated(1)
+	assert.False(t, workerPodsTerminated(pods))
+}
+
+func TestNewLotusProgress(t *testing.T) {
+	p := newLotusProgress(&model.Progress{
+		Elapsed:         90 * time.Second,
+		Remaining:       30 * time.Second,
+		RPS:             1523.4,
+		ErrorPercentage: model.NoDataValue,
+	})
+	assert.Equal(t, int64(90), p.ElapsedSeconds)
+	assert.Equal(t, int64(30), p.RemainingSeconds)
+	assert.Equal(t, "1523.40", p.RPS)
+	assert.Equal(t, "-", p.ErrorPercentage)
+}
+
+func TestPreflightRetries(t *testing.T) {
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	retries := newPreflightRetries()
+	runs := 0
+	release := make(chan struct{})
+	run := func() error {
+		runs++
+		<-release
+		return errors.New("unavailable")
+	}
+	done := make(chan struct{})
+	poll := func(at time.Time) (bool, error) {
+		return retries.poll("default/test", at, run, func() { done <- struct{}{} })
+	}
+
+	// The round runs in the background and its result is handled by the next poll.
+	finished, _ := poll(now)
+	assert.False(t, finished)
+	finished, _ = poll(now)
+	assert.False(t, finished)
+	close(release)
+	<-done
+	finished, err := poll(now)
+	assert.True(t, finished)
+	assert.EqualError(t, err, "unavailable")
+	assert.Equal(t, 1, runs)
+
+	assert.Equal(t, 1, retries.fail("default/test", now))
+	finished, _ = poll(now.Add(time.Second))
+	assert.False(t, finished)
+	assert.Equal(t, 1, runs)
+	poll(now.Add(preflightRetryInterval))
+	<-done
+	assert.Equal(t, 2, runs)
+	assert.Equal(t, 2, retries.fail("default/test", now.Add(preflightRetryInterval)))
+
+	retries.forget("default/test")
+	assert.Equal(t, 1, retries.fail("default/test", now))
+}
@@ -0,0 +1,68 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package controller
+
+import (
+	"fmt"
+
+	batchv1 "k8s.io/api/batch/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	lotusv1beta1 "github.com/lotusload/lotus/pkg/app/lotus/apis/lotus/v1beta1"
+	"github.com/lotusload/lotus/pkg/app/lotus/resource"
+)
+
+// storeHandoff collects the data written by the containers of the given
+// preparer job and stores it in the handoff Secret of the Lotus,
+// which is injected into the worker and cleaner containers.
+func (c *Controller) storeHandoff(lotus *lotusv1beta1.Lotus, job *batchv1.Job) error {
+	selector, err := metav1.LabelSelectorAsSelector(job.Spec.Selector)
+	if err != nil {
+		return err
+	}
+	pods, err := c.kubeClient.ListPods(lotus.Namespace, selector.String())
+	if err != nil {
+		return err
+	}
+	data := make(map[string][]byte)
+	for _, pod := range pods {
+		for _, status := range pod.Status.ContainerStatuses {
+			terminated := status.State.Terminated
+			if terminated == nil || terminated.Message == "" {
+				continue
+			}
+			values, err := resource.ParseHandoff(terminated.Message)
+			if err != nil {
+				return fmt.Errorf("invalid handoff data from container %s of pod %s: %v", status.Name, pod.Name, err)
+			}
+			for k, v := range values {
+				data[k] = v
+			}
+		}
+	}
+	factory := resource.NewFactory(lotus, c.configFile)
+	secret, err := factory.NewHandoffSecret(data)
+	if err != nil {
+		return err
+	}
+	_, err = c.kubeClient.ApplySecret(factory.HandoffName(), lotus.Namespace, secret)
+	return err
+}
@@ -0,0 +1,330 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"google.golang.org/grpc"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	corev1 "k8s.io/api/core/v1"
+	apiresource "k8s.io/apimachinery/pkg/api/resource"
+
+	lotusv1beta1 "github.com/lotusload/lotus/pkg/app/lotus/apis/lotus/v1beta1"
+	"github.com/lotusload/lotus/pkg/app/lotus/config"
+	"github.com/lotusload/lotus/pkg/app/lotus/datasource"
+	dsregistry "github.com/lotusload/lotus/pkg/app/lotus/datasource/registry"
+	"github.com/lotusload/lotus/pkg/app/lotus/resource"
+)
+
+const (
+	defaultPreflightTimeout = 10 * time.Second
+	// maxPreflightDuration bounds a round of preflight checks.
+	maxPreflightDuration = 30 * time.Second
+	// preflightAttempts is the number of rounds which must fail in a row before the Lotus fails.
+	preflightAttempts      = 3
+	preflightRetryInterval = 10 * time.Second
+)
+
+// preflightRetries runs the rounds of preflight checks of the pending Lotuses in the background,
+// so that slow probes do not block the syncs of the other Lotuses, and remembers their failed rounds.
+type preflightRetries struct {
+	mu       sync.Mutex
+	failures map[string]int
+	next     map[string]time.Time
+	// The rounds in progress, and the results of the finished rounds which have not been handled yet.
+	running map[string]struct{}
+	results map[string]error
+}
+
+func newPreflightRetries() *preflightRetries {
+	return &preflightRetries{
+		failures: make(map[string]int),
+		next:     make(map[string]time.Time),
+		running:  make(map[string]struct{}),
+		results:  make(map[string]error),
+	}
+}
+
+// poll returns the result of the finished round of the given Lotus, which is handled only once.
+// Otherwise it starts a round running run in the background if none is running and
+// the retry interval has passed, so that the other syncs of a Lotus do not count as attempts.
+// done is called when the round has finished, so that the Lotus is synced again to handle its result.
+func (r *preflightRetries) poll(key string, now time.Time, run func() error, done func()) (bool, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if err, ok := r.results[key]; ok {
+		delete(r.results, key)
+		return true, err
+	}
+	if _, ok := r.running[key]; ok || now.Before(r.next[key]) {
+		return false, nil
+	}
+	r.running[key] = struct{}{}
+	go func() {
+		err := run()
+		r.mu.Lock()
+		delete(r.running, key)
+		r.results[key] = err
+		r.mu.Unlock()
+		done()
+	}()
+	return false, nil
+}
+
+// fail records a failed round at the given time and returns the number of failed rounds in a row.
+func (r *preflightRetries) fail(key string, now time.Time) int {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.failures[key]++
+	r.next[key] = now.Add(preflightRetryInterval)
+	return r.failures[key]
+}
+
+func (r *preflightRetries) forget(key string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	delete(r.failures, key)
+	delete(r.next, key)
+	delete(r.results, key)
+}
+
+// runPreflight runs all preflight checks of the given Lotus and returns
+// an error describing the first one which did not pass.
+// The whole round is bounded by maxPreflightDuration.
+func (c *Controller) runPreflight(ctx context.Context, lotus *lotusv1beta1.Lotus, cfg *config.Config) error {
+	ctx, cancel := context.WithTimeout(ctx, maxPreflightDuration)
+	defer cancel()
+	preflight := lotus.Spec.Preflight
+	timeout := defaultPreflightTimeout
+	if preflight.TimeoutSeconds != nil {
+		timeout = time.Duration(*preflight.TimeoutSeconds) * time.Second
+	}
+	for _, probe := range preflight.HTTPProbes {
+		if err := c.probeHTTP(ctx, probe, timeout); err != nil {
+			return fmt.Errorf("http probe %s failed: %v", probe.URL, err)
+		}
+	}
+	for _, probe := range preflight.GRPCProbes {
+		if err := probeGRPC(ctx, probe, timeout); err != nil {
+			return fmt.Errorf("grpc probe %s failed: %v", probe.Address, err)
+		}
+	}
+	dataSources := make(map[string]datasource.DataSource)
+	for _, condition := range preflight.Conditions {
+		if err := c.checkCondition(ctx, condition, cfg, dataSources, timeout); err != nil {
+			return fmt.Errorf("condition %s failed: %v", condition.Name, err)
+		}
+	}
+	if preflight.CheckQuota {
+		quotas, err := c.kubeClient.ListResourceQuotas(lotus.Namespace)
+		if err != nil {
+			return fmt.Errorf("failed to list resource quotas: %v", err)
+		}
+		factory := resource.NewFactory(lotus, c.configFile)
+		required := workerResourceRequirements(resource.WithResolvedTemplates(lotus).Spec.Worker, factory.WorkerReplicas())
+		if err := checkQuotas(quotas, required); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (c *Controller) probeHTTP(ctx context.Context, probe lotusv1beta1.LotusHTTPProbe, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	req, err := http.NewRequest(http.MethodGet, probe.URL, nil)
+	if err != nil {
+		return err
+	}
+	resp, err := c.httpClient.Do(req.WithContext(ctx))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if probe.ExpectedStatus != 0 {
+		if resp.StatusCode != int(probe.ExpectedStatus) {
+			return fmt.Errorf("unexpected status code %d, expected %d", resp.StatusCode, probe.ExpectedStatus)
+		}
+		return nil
+	}
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("unexpected status code %d", resp.StatusCode)
+	}
+	return nil
+}
+
+func probeGRPC(ctx context.Context, probe lotusv1beta1.LotusGRPCProbe, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	conn, err := grpc.DialContext(ctx, probe.Address, grpc.WithInsecure(), grpc.WithBlock())
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	resp, err := healthpb.NewHealthClient(conn).Check(ctx, &healthpb.HealthCheckRequest{
+		Service: probe.Service,
+	})
+	if err != nil {
+		return err
+	}
+	if resp.Status != healthpb.HealthCheckResponse_SERVING {
+		return fmt.Errorf("service is %s", resp.Status)
+	}
+	return nil
+}
+
+// checkCondition evaluates the given condition against its data source,
+// which is built once and shared through dataSources by all conditions of the round.
+func (c *Controller) checkCondition(ctx context.Context, condition lotusv1beta1.LotusPreflightCondition, cfg *config.Config, dataSources map[string]datasource.DataSource, timeout time.Duration) error {
+	ds, ok := dataSources[condition.DataSource]
+	if !ok {
+		var err error
+		ds, err = c.buildDataSource(condition.DataSource, cfg)
+		if err != nil {
+			return err
+		}
+		dataSources[condition.DataSource] = ds
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	samples, err := ds.Query(ctx, condition.Expr, time.Now())
+	if err != nil {
+		return err
+	}
+	if len(samples) == 0 {
+		return fmt.Errorf("query %q returned no result", condition.Expr)
+	}
+	return nil
+}
+
+func (c *Controller) buildDataSource(name string, cfg *config.Config) (datasource.DataSource, error) {
+	var dsConfig *config.DataSource
+	for _, ds := range cfg.DataSources {
+		if ds.Name == name {
+			dsConfig = ds
+			break
+		}
+	}
+	if dsConfig == nil {
+		return nil, fmt.Errorf("data source %q is not configured", name)
+	}
+	builder, err := dsregistry.Default().Get(dsConfig.DataSourceType())
+	if err != nil {
+		return nil, err
+	}
+	return builder.Build(dsConfig, datasource.BuildOptions{
+		Logger: c.logger,
+	})
+}
+
+// workerResourceRequirements returns the total amount of resources
+// requested by all worker replicas, keyed by the names used in resource quotas.
+func workerResourceRequirements(worker *lotusv1beta1.LotusSpecWorker, replicas int32) corev1.ResourceList {
+	required := corev1.ResourceList{
+		corev1.ResourcePods: *apiresource.NewQuantity(int64(replicas), apiresource.DecimalSI),
+	}
+	add := func(name corev1.ResourceName, q apiresource.Quantity) {
+		for i := int32(0); i < replicas; i++ {
+			total := required[name]
+			total.Add(q)
+			required[name] = total
+		}
+	}
+	for _, container := range worker.Containers {
+		if q, ok := container.Resources.Requests[corev1.ResourceCPU]; ok {
+			add(corev1.ResourceRequestsCPU, q)
+			add(corev1.ResourceCPU, q)
+		}
+		if q, ok := container.Resources.Requests[corev1.ResourceMemory]; ok {
+			add(corev1.ResourceRequestsMemory, q)
+			add(corev1.ResourceMemory, q)
+		}
+		if q, ok := container.Resources.Limits[corev1.ResourceCPU]; ok {
+			add(corev1.ResourceLimitsCPU, q)
+		}
+		if q, ok := container.Resources.Limits[corev1.ResourceMemory]; ok {
+			add(corev1.ResourceLimitsMemory, q)
+		}
+	}
+	return required
+}
+
+// checkQuotas returns an error if any of the given quotas
+// does not have enough room for the required resources.
+// The quotas with scopes are skipped, since they may not apply to the worker pods.
+func checkQuotas(quotas []corev1.ResourceQuota, required corev1.ResourceList) error {
+	for _, quota := range quotas {
+		if len(quota.Spec.Scopes) > 0 || quota.Spec.ScopeSelector != nil {
+			continue
+		}
+		for name, hard := range quota.Status.Hard {
+			need, ok := required[name]
+			if !ok {
+				continue
+			}
+			available := hard.DeepCopy()
+			if used, ok := quota.Status.Used[name]; ok {
+				available.Sub(used)
+			}
+			if need.Cmp(available) > 0 {
+				return fmt.Errorf("insufficient %s in resource quota %s: required %s, available %s",
+					name, quota.Name, need.String(), available.String())
+			}
+		}
+	}
+	return nil
+}
+
+// retryPreflight requeues the given Lotus to run the preflight checks again after preflightRetryInterval,
+// or marks it as failed once they have failed preflightAttempts times in a row.
+func (c *Controller) retryPreflight(key string, lotus *lotusv1beta1.Lotus, err error) error {
+	failures := c.preflightRetries.fail(key, time.Now())
+	if failures >= preflightAttempts {
+		c.preflightRetries.forget(key)
+		return c.failPreflight(lotus, err)
+	}
+	c.logger.Info("preflight check failed, will retry",
+		zap.String("lotus", lotus.Name),
+		zap.Int("failures", failures),
+		zap.Error(err))
+	c.recorder.Event(lotus, corev1.EventTypeWarning, "PreflightRetrying",
+		fmt.Sprintf("attempt %d of %d failed: %v", failures, preflightAttempts, err))
+	c.workqueue.AddAfter(key, preflightRetryInterval)
+	return nil
+}
+
+// failPreflight marks the given Lotus as failed because of a preflight check.
+func (c *Controller) failPreflight(lotus *lotusv1beta1.Lotus, err error) error {
+	c.logger.Info("preflight check failed",
+		zap.String("lotus", lotus.Name),
+		zap.Error(err))
+	c.recorder.Event(lotus, corev1.EventTypeWarning, "PreflightFailed", err.Error())
+	lotusCopy := lotus.DeepCopy()
+	lotusCopy.Status.QueuePosition = nil
+	lotusCopy.Status.Reason = fmt.Sprintf("PreflightFailed: %v", err)
+	return c.updateLotusStatus(lotusCopy, lotusv1beta1.LotusFailed)
+}
@@ -0,0 +1,98 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package controller
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"go.uber.org/zap"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	lotusv1beta1 "github.com/lotusload/lotus/pkg/app/lotus/apis/lotus/v1beta1"
+	"github.com/lotusload/lotus/pkg/app/lotus/model"
+	"github.com/lotusload/lotus/pkg/app/lotus/resource"
+)
+
+// publishProgress fetches the live progress from the monitor and stores it in the status of the Lotus.
+// The status is only updated when the monitor has observed new metrics.
+// Since the progress is informational, failures are only logged and the given Lotus is returned.
+func (c *Controller) publishProgress(lotus *lotusv1beta1.Lotus) *lotusv1beta1.Lotus {
+	progress, err := c.fetchProgress(lotus)
+	if err != nil {
+		c.logger.Warn("failed to fetch progress from monitor",
+			zap.String("lotus", lotus.Name),
+			zap.Error(err))
+		return lotus
+	}
+	if progress.UpdatedTimestamp.IsZero() {
+		return lotus
+	}
+	if current := lotus.Status.Progress; current != nil && current.UpdateTime.Unix() == progress.UpdatedTimestamp.Unix() {
+		return lotus
+	}
+	lotusCopy := lotus.DeepCopy()
+	lotusCopy.Status.Progress = newLotusProgress(progress)
+	updated, err := c.lotusclientset.LotusV1beta1().Lotuses(lotus.Namespace).Update(lotusCopy)
+	if err != nil {
+		c.logger.Warn("failed to publish progress",
+			zap.String("lotus", lotus.Name),
+			zap.Error(err))
+		return lotus
+	}
+	return updated
+}
+
+func (c *Controller) fetchProgress(lotus *lotusv1beta1.Lotus) (*model.Progress, error) {
+	factory := resource.NewFactory(lotus, c.configFile)
+	resp, err := c.httpClient.Get(factory.MonitorAddress() + "/progress")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code from monitor: %d", resp.StatusCode)
+	}
+	progress := &model.Progress{}
+	if err := json.NewDecoder(resp.Body).Decode(progress); err != nil {
+		return nil, err
+	}
+	return progress, nil
+}
+
+func newLotusProgress(p *model.Progress) *lotusv1beta1.LotusProgress {
+	return &lotusv1beta1.LotusProgress{
+		UpdateTime:       metav1.NewTime(p.UpdatedTimestamp),
+		ElapsedSeconds:   int64(p.Elapsed.Seconds()),
+		RemainingSeconds: int64(p.Remaining.Seconds()),
+		RPS:              formatProgressValue(p.RPS),
+		ErrorPercentage:  formatProgressValue(p.ErrorPercentage),
+		FiringChecks:     p.FiringChecks,
+	}
+}
+
+func formatProgressValue(v float64) string {
+	if v == model.NoDataValue {
+		return "-"
+	}
+	return strconv.FormatFloat(v, 'f', 2, 64)
+}
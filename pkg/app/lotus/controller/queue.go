@@ -0,0 +1,252 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package controller
+
+import (
+	"context"
+	"sort"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/client-go/tools/cache"
+
+	lotusv1beta1 "github.com/lotusload/lotus/pkg/app/lotus/apis/lotus/v1beta1"
+	"github.com/lotusload/lotus/pkg/app/lotus/config"
+)
+
+// startedLotuses remembers the Lotuses started by this controller
+// until the informer cache observes their new phase, so that the
+// concurrency limits are not exceeded because of a stale cache.
+type startedLotuses struct {
+	mu   sync.Mutex
+	keys map[string]struct{}
+}
+
+func (s *startedLotuses) add(key string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.keys[key] = struct{}{}
+}
+
+func (s *startedLotuses) has(key string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.keys[key]
+	return ok
+}
+
+// retain forgets the Lotuses which are no longer seen as Pending.
+func (s *startedLotuses) retain(pending map[string]struct{}) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for key := range s.keys {
+		if _, ok := pending[key]; !ok {
+			delete(s.keys, key)
+		}
+	}
+}
+
+// syncPendingLotus starts the given Lotus if it fits into the concurrency limits
+// and passes the preflight checks, otherwise keeps it in Pending and updates
+// its position in the queue.
+func (c *Controller) syncPendingLotus(lotus *lotusv1beta1.Lotus) error {
+	cfg, err := config.FromFile(c.configFile)
+	if err != nil {
+		c.logger.Error("failed to load config", zap.Error(err))
+		return err
+	}
+	lotuses, err := c.lotusesLister.List(labels.Everything())
+	if err != nil {
+		return err
+	}
+	key, err := cache.MetaNamespaceKeyFunc(lotus)
+	if err != nil {
+		return err
+	}
+	// Wait until the informer cache observes the new phase.
+	if c.started.has(key) {
+		return nil
+	}
+	pending := make(map[string]struct{})
+	for _, l := range lotuses {
+		if l.Status.Phase == lotusv1beta1.LotusPending {
+			k, _ := cache.MetaNamespaceKeyFunc(l)
+			pending[k] = struct{}{}
+		}
+	}
+	c.started.retain(pending)
+
+	positions := queuePositions(lotuses, c.started.has, cfg.Concurrency)
+	position, queued := positions[key]
+	if !queued {
+		if lotus.Spec.Preflight != nil {
+			lotusCopy := lotus.DeepCopy()
+			finished, err := c.preflightRetries.poll(key, time.Now(), func() error {
+				return c.runPreflight(context.Background(), lotusCopy, cfg)
+			}, func() {
+				c.workqueue.Add(key)
+			})
+			if !finished {
+				return nil
+			}
+			if err != nil {
+				return c.retryPreflight(key, lotus, err)
+			}
+			c.preflightRetries.forget(key)
+		}
+		lotusCopy := lotus.DeepCopy()
+		lotusCopy.Status.QueuePosition = nil
+		if err := c.updateLotusStatus(lotusCopy, lotusv1beta1.LotusPreparing); err != nil {
+			return err
+		}
+		c.started.add(key)
+		return nil
+	}
+	c.logger.Info("lotus is queued because of the concurrency limits",
+		zap.String("key", key),
+		zap.Int32("position", position))
+	if lotus.Status.QueuePosition != nil && *lotus.Status.QueuePosition == position {
+		return nil
+	}
+	lotusCopy := lotus.DeepCopy()
+	lotusCopy.Status.QueuePosition = &position
+	_, err = c.lotusclientset.LotusV1beta1().Lotuses(lotus.Namespace).Update(lotusCopy)
+	return err
+}
+
+// enqueuePendingLotuses re-evaluates the queue, e.g. after a Lotus has finished.
+func (c *Controller) enqueuePendingLotuses() {
+	lotuses, err := c.lotusesLister.List(labels.Everything())
+	if err != nil {
+		c.logger.Error("failed to list lotuses", zap.Error(err))
+		return
+	}
+	for _, lotus := range lotuses {
+		if lotus.Status.Phase == lotusv1beta1.LotusPending {
+			c.enqueueLotus(lotus)
+		}
+	}
+}
+
+// queuePositions returns the 1-based queue positions of the Pending Lotuses
+// which can not be started now without exceeding the concurrency limits.
+// The Pending Lotuses are admitted in order of priority and creation time.
+// The started function reports the Pending Lotuses which have already been started.
+func queuePositions(lotuses []*lotusv1beta1.Lotus, started func(key string) bool, limits *config.Concurrency) map[string]int32 {
+	counter := newConcurrencyCounter(limits)
+	pending := make([]*lotusv1beta1.Lotus, 0)
+	for _, lotus := range lotuses {
+		key, _ := cache.MetaNamespaceKeyFunc(lotus)
+		switch {
+		case isActive(lotus.Status.Phase):
+			counter.add(lotus)
+		case lotus.Status.Phase == lotusv1beta1.LotusPending && started(key):
+			counter.add(lotus)
+		case lotus.Status.Phase == lotusv1beta1.LotusPending:
+			pending = append(pending, lotus)
+		}
+	}
+	sort.SliceStable(pending, func(i, j int) bool {
+		a, b := pending[i], pending[j]
+		if a.Spec.Priority != b.Spec.Priority {
+			return a.Spec.Priority > b.Spec.Priority
+		}
+		if !a.CreationTimestamp.Equal(&b.CreationTimestamp) {
+			return a.CreationTimestamp.Before(&b.CreationTimestamp)
+		}
+		if a.Namespace != b.Namespace {
+			return a.Namespace < b.Namespace
+		}
+		return a.Name < b.Name
+	})
+
+	positions := make(map[string]int32)
+	for _, lotus := range pending {
+		if counter.fits(lotus) {
+			counter.add(lotus)
+			continue
+		}
+		key, _ := cache.MetaNamespaceKeyFunc(lotus)
+		positions[key] = int32(len(positions) + 1)
+	}
+	return positions
+}
+
+func isActive(phase lotusv1beta1.LotusPhase) bool {
+	switch phase {
+	case lotusv1beta1.LotusPreparing,
+		lotusv1beta1.LotusRunning,
+		lotusv1beta1.LotusCleaning,
+		lotusv1beta1.LotusFailureCleaning:
+		return true
+	}
+	return false
+}
+
+type concurrencyCounter struct {
+	limits     *config.Concurrency
+	total      uint32
+	namespaces map[string]uint32
+	targets    map[string]uint32
+}
+
+func newConcurrencyCounter(limits *config.Concurrency) *concurrencyCounter {
+	if limits == nil {
+		limits = &config.Concurrency{}
+	}
+	return &concurrencyCounter{
+		limits:     limits,
+		namespaces: make(map[string]uint32),
+		targets:    make(map[string]uint32),
+	}
+}
+
+func (c *concurrencyCounter) target(lotus *lotusv1beta1.Lotus) string {
+	if c.limits.TargetLabel == "" {
+		return ""
+	}
+	return lotus.Labels[c.limits.TargetLabel]
+}
+
+func (c *concurrencyCounter) fits(lotus *lotusv1beta1.Lotus) bool {
+	if max := c.limits.MaxRunning; max > 0 && c.total >= max {
+		return false
+	}
+	if max := c.limits.MaxRunningPerNamespace; max > 0 && c.namespaces[lotus.Namespace] >= max {
+		return false
+	}
+	if target := c.target(lotus); target != "" {
+		if max := c.limits.MaxRunningPerTarget; max > 0 && c.targets[target] >= max {
+			return false
+		}
+	}
+	return true
+}
+
+func (c *concurrencyCounter) add(lotus *lotusv1beta1.Lotus) {
+	c.total++
+	c.namespaces[lotus.Namespace]++
+	if target := c.target(lotus); target != "" {
+		c.targets[target]++
+	}
+}
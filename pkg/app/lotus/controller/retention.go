@@ -0,0 +1,124 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package controller
+
+import (
+	"time"
+
+	"go.uber.org/zap"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/cache"
+
+	lotusv1beta1 "github.com/lotusload/lotus/pkg/app/lotus/apis/lotus/v1beta1"
+	"github.com/lotusload/lotus/pkg/app/lotus/resource"
+)
+
+const defaultRetentionGracePeriod = 5 * time.Minute
+
+// syncFinishedLotus deletes the per-test Prometheus and monitor resources of
+// a finished Lotus once its retention grace period has passed.
+// The final report has already been sent by the monitor at this point.
+func (c *Controller) syncFinishedLotus(lotus *lotusv1beta1.Lotus) error {
+	if lotus.Status.ResourcesDeletionTime != nil || !shouldDeleteResources(lotus) {
+		return nil
+	}
+	finishedTime := completionTime(lotus)
+	if finishedTime == nil {
+		return nil
+	}
+	remaining := finishedTime.Add(retentionGracePeriod(lotus)).Sub(time.Now())
+	if remaining > 0 {
+		key, err := cache.MetaNamespaceKeyFunc(lotus)
+		if err != nil {
+			return err
+		}
+		c.workqueue.AddAfter(key, remaining)
+		return nil
+	}
+	if err := c.deleteTestResources(lotus); err != nil {
+		c.logger.Error("failed to delete test resources", zap.Error(err))
+		return err
+	}
+	lotusCopy := lotus.DeepCopy()
+	now := metav1.Now()
+	lotusCopy.Status.ResourcesDeletionTime = &now
+	if _, err := c.lotusclientset.LotusV1beta1().Lotuses(lotus.Namespace).Update(lotusCopy); err != nil {
+		return err
+	}
+	c.recorder.Event(lotus, corev1.EventTypeNormal, "ResourcesDeleted",
+		"deleted prometheus and monitor resources according to the retention policy")
+	return nil
+}
+
+func (c *Controller) deleteTestResources(lotus *lotusv1beta1.Lotus) error {
+	factory := resource.NewFactory(lotus, c.configFile)
+	prometheus := factory.PrometheusName()
+	if err := c.kubeClient.DeletePod(prometheus, lotus.Namespace); err != nil {
+		return err
+	}
+	if err := c.kubeClient.DeleteService(prometheus, lotus.Namespace); err != nil {
+		return err
+	}
+	if err := c.kubeClient.DeleteConfigMap(prometheus, lotus.Namespace); err != nil {
+		return err
+	}
+	monitor := factory.MonitorJobName()
+	if err := c.kubeClient.DeleteService(monitor, lotus.Namespace); err != nil {
+		return err
+	}
+	if err := c.kubeClient.DeleteConfigMap(monitor, lotus.Namespace); err != nil {
+		return err
+	}
+	return c.kubeClient.DeleteSecret(monitor, lotus.Namespace)
+}
+
+func shouldDeleteResources(lotus *lotusv1beta1.Lotus) bool {
+	switch lotus.Spec.RetentionPolicy {
+	case lotusv1beta1.LotusRetentionDeleteAlways:
+		return true
+	case lotusv1beta1.LotusRetentionDeleteOnSuccess:
+		return lotus.Status.Phase == lotusv1beta1.LotusSucceeded
+	}
+	return false
+}
+
+func retentionGracePeriod(lotus *lotusv1beta1.Lotus) time.Duration {
+	if lotus.Spec.RetentionGracePeriodSeconds == nil {
+		return defaultRetentionGracePeriod
+	}
+	return time.Duration(*lotus.Spec.RetentionGracePeriodSeconds) * time.Second
+}
+
+// completionTime returns the time when the last stage of the given Lotus completed.
+func completionTime(lotus *lotusv1beta1.Lotus) *metav1.Time {
+	var latest *metav1.Time
+	for _, t := range []*metav1.Time{
+		lotus.Status.PreparerCompletionTime,
+		lotus.Status.WorkerCompletionTime,
+		lotus.Status.CleanerCompletionTime,
+	} {
+		if t != nil && (latest == nil || latest.Before(t)) {
+			latest = t
+		}
+	}
+	return latest
+}
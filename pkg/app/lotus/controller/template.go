@@ -0,0 +1,127 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package controller
+
+import (
+	"fmt"
+
+	"github.com/ghodss/yaml"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+
+	lotusv1beta1 "github.com/lotusload/lotus/pkg/app/lotus/apis/lotus/v1beta1"
+)
+
+const defaultTemplateConfigMapKey = "podSpec.yaml"
+
+// invalidTemplateError is returned when a referenced template
+// does not exist or is invalid, so retrying does not help.
+type invalidTemplateError struct {
+	error
+}
+
+// resolveTemplates stores the containers and volumes of the pod specs referenced
+// by the stages of the given Lotus in its status, so that the test keeps
+// running the same pods even if the referenced objects are changed later.
+// The spec is left untouched.
+func (c *Controller) resolveTemplates(lotus *lotusv1beta1.Lotus) error {
+	resolve := func(stage string, ref *lotusv1beta1.LotusTemplateRef) (*lotusv1beta1.LotusPodSpec, error) {
+		if ref == nil {
+			return nil, nil
+		}
+		spec, err := c.getTemplatePodSpec(ref, lotus.Namespace)
+		if errors.IsNotFound(err) {
+			err = invalidTemplateError{err}
+		}
+		if _, ok := err.(invalidTemplateError); ok {
+			return nil, invalidTemplateError{fmt.Errorf("failed to resolve template of %s: %v", stage, err)}
+		}
+		if err != nil {
+			return nil, err
+		}
+		return &lotusv1beta1.LotusPodSpec{
+			Containers: spec.Containers,
+			Volumes:    spec.Volumes,
+		}, nil
+	}
+	resolved := &lotusv1beta1.LotusResolvedTemplates{}
+	var err error
+	if p := lotus.Spec.Preparer; p != nil {
+		if resolved.Preparer, err = resolve("preparer", p.TemplateRef); err != nil {
+			return err
+		}
+	}
+	if w := lotus.Spec.Worker; w != nil {
+		if resolved.Worker, err = resolve("worker", w.TemplateRef); err != nil {
+			return err
+		}
+	}
+	if cl := lotus.Spec.Cleaner; cl != nil {
+		if resolved.Cleaner, err = resolve("cleaner", cl.TemplateRef); err != nil {
+			return err
+		}
+	}
+	if resolved.Preparer != nil || resolved.Worker != nil || resolved.Cleaner != nil {
+		lotus.Status.ResolvedTemplates = resolved
+	}
+	return nil
+}
+
+func (c *Controller) getTemplatePodSpec(ref *lotusv1beta1.LotusTemplateRef, namespace string) (*corev1.PodSpec, error) {
+	switch ref.Kind {
+	case lotusv1beta1.LotusTemplatePodTemplate:
+		template, err := c.kubeClient.GetPodTemplate(ref.Name, namespace)
+		if err != nil {
+			return nil, err
+		}
+		return &template.Template.Spec, nil
+	case lotusv1beta1.LotusTemplateConfigMap:
+		configMap, err := c.kubeClient.GetConfigMap(ref.Name, namespace)
+		if err != nil {
+			return nil, err
+		}
+		key := ref.Key
+		if key == "" {
+			key = defaultTemplateConfigMapKey
+		}
+		data, ok := configMap.Data[key]
+		if !ok {
+			return nil, invalidTemplateError{fmt.Errorf("key %s was not found in configmap %s", key, ref.Name)}
+		}
+		spec, err := parsePodSpec([]byte(data))
+		if err != nil {
+			return nil, invalidTemplateError{err}
+		}
+		return spec, nil
+	}
+	return nil, invalidTemplateError{fmt.Errorf("unsupported template kind: %s", ref.Kind)}
+}
+
+func parsePodSpec(data []byte) (*corev1.PodSpec, error) {
+	spec := &corev1.PodSpec{}
+	if err := yaml.Unmarshal(data, spec); err != nil {
+		return nil, fmt.Errorf("invalid pod spec: %v", err)
+	}
+	if len(spec.Containers) == 0 {
+		return nil, fmt.Errorf("invalid pod spec: no container")
+	}
+	return spec, nil
+}
@@ -0,0 +1,386 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"hash/fnv"
+	"sort"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
+	"k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/apimachinery/pkg/util/wait"
+	appsinformers "k8s.io/client-go/informers/apps/v1"
+	appslisters "k8s.io/client-go/listers/apps/v1"
+	"k8s.io/client-go/tools/cache"
+	"k8s.io/client-go/util/workqueue"
+
+	lotusv1beta1 "github.com/lotusload/lotus/pkg/app/lotus/apis/lotus/v1beta1"
+	clientset "github.com/lotusload/lotus/pkg/app/lotus/client/clientset/versioned"
+	informers "github.com/lotusload/lotus/pkg/app/lotus/client/informers/externalversions/lotus/v1beta1"
+	listers "github.com/lotusload/lotus/pkg/app/lotus/client/listers/lotus/v1beta1"
+	"github.com/lotusload/lotus/pkg/app/lotus/resource"
+)
+
+const (
+	// TriggerLabel is set on the Lotuses created by a LotusTrigger.
+	TriggerLabel = "lotus.lotusload.com/trigger"
+	// TriggerImageAnnotation is the image whose rollout created the Lotus.
+	// It is passed to the monitor to be shown in the result.
+	TriggerImageAnnotation = resource.TestedImageAnnotation
+	// TriggerTargetAnnotation is the workload whose rollout created the Lotus.
+	TriggerTargetAnnotation = "lotus.lotusload.com/target"
+)
+
+// TriggerController creates Lotuses from LotusTriggers
+// when their target workloads have rolled out new images.
+type TriggerController struct {
+	lotusclientset clientset.Interface
+
+	deploymentsLister  appslisters.DeploymentLister
+	deploymentsSynced  cache.InformerSynced
+	statefulSetsLister appslisters.StatefulSetLister
+	statefulSetsSynced cache.InformerSynced
+	triggersLister     listers.LotusTriggerLister
+	triggersSynced     cache.InformerSynced
+
+	workqueue workqueue.RateLimitingInterface
+	logger    *zap.Logger
+}
+
+func NewTriggerController(
+	lotusclientset clientset.Interface,
+	deploymentInformer appsinformers.DeploymentInformer,
+	statefulSetInformer appsinformers.StatefulSetInformer,
+	triggerInformer informers.LotusTriggerInformer,
+	logger *zap.Logger) *TriggerController {
+
+	controller := &TriggerController{
+		lotusclientset:     lotusclientset,
+		deploymentsLister:  deploymentInformer.Lister(),
+		deploymentsSynced:  deploymentInformer.Informer().HasSynced,
+		statefulSetsLister: statefulSetInformer.Lister(),
+		statefulSetsSynced: statefulSetInformer.Informer().HasSynced,
+		triggersLister:     triggerInformer.Lister(),
+		triggersSynced:     triggerInformer.Informer().HasSynced,
+		workqueue:          workqueue.NewNamedRateLimitingQueue(workqueue.DefaultControllerRateLimiter(), "LotusTriggers"),
+		logger:             logger.Named("trigger-controller"),
+	}
+	triggerInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		AddFunc: controller.enqueueTrigger,
+		UpdateFunc: func(old, new interface{}) {
+			controller.enqueueTrigger(new)
+		},
+	})
+	deploymentInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(old, new interface{}) {
+			d := new.(*appsv1.Deployment)
+			controller.onWorkload(lotusv1beta1.LotusTriggerDeployment, d.Namespace, d.Labels)
+		},
+	})
+	statefulSetInformer.Informer().AddEventHandler(cache.ResourceEventHandlerFuncs{
+		UpdateFunc: func(old, new interface{}) {
+			s := new.(*appsv1.StatefulSet)
+			controller.onWorkload(lotusv1beta1.LotusTriggerStatefulSet, s.Namespace, s.Labels)
+		},
+	})
+	return controller
+}
+
+func (c *TriggerController) Run(ctx context.Context, workers int) error {
+	defer runtime.HandleCrash()
+	defer c.workqueue.ShutDown()
+
+	c.logger.Info("starting LotusTrigger controller")
+	if ok := cache.WaitForCacheSync(ctx.Done(), c.deploymentsSynced, c.statefulSetsSynced, c.triggersSynced); !ok {
+		return fmt.Errorf("failed to wait for caches to sync")
+	}
+	for i := 0; i < workers; i++ {
+		go wait.Until(c.runWorker, time.Second, ctx.Done())
+	}
+	c.logger.Info("started workers", zap.Int("workers", workers))
+	<-ctx.Done()
+	c.logger.Info("shutting down workers")
+	return nil
+}
+
+func (c *TriggerController) runWorker() {
+	for c.processNextWorkItem() {
+	}
+}
+
+func (c *TriggerController) processNextWorkItem() bool {
+	obj, shutdown := c.workqueue.Get()
+	if shutdown {
+		return false
+	}
+	defer c.workqueue.Done(obj)
+	key, ok := obj.(string)
+	if !ok {
+		c.workqueue.Forget(obj)
+		runtime.HandleError(fmt.Errorf("expected string in workqueue but got %#v", obj))
+		return true
+	}
+	if err := c.syncHandler(key); err != nil {
+		c.workqueue.AddRateLimited(key)
+		runtime.HandleError(fmt.Errorf("error syncing '%s': %s, requeuing", key, err.Error()))
+		return true
+	}
+	c.workqueue.Forget(obj)
+	return true
+}
+
+func (c *TriggerController) enqueueTrigger(obj interface{}) {
+	key, err := cache.MetaNamespaceKeyFunc(obj)
+	if err != nil {
+		runtime.HandleError(err)
+		return
+	}
+	c.workqueue.Add(key)
+}
+
+// onWorkload enqueues all triggers targeting the updated workload.
+func (c *TriggerController) onWorkload(kind lotusv1beta1.LotusTriggerTargetKind, namespace string, workloadLabels map[string]string) {
+	triggers, err := c.triggersLister.List(labels.Everything())
+	if err != nil {
+		c.logger.Error("failed to list triggers", zap.Error(err))
+		return
+	}
+	for _, trigger := range triggers {
+		target := trigger.Spec.Target
+		if target.Kind != kind || target.Namespace != namespace {
+			continue
+		}
+		selector, err := metav1.LabelSelectorAsSelector(target.Selector)
+		if err != nil {
+			continue
+		}
+		if selector.Matches(labels.Set(workloadLabels)) {
+			c.enqueueTrigger(trigger)
+		}
+	}
+}
+
+func (c *TriggerController) syncHandler(key string) error {
+	namespace, name, err := cache.SplitMetaNamespaceKey(key)
+	if err != nil {
+		runtime.HandleError(fmt.Errorf("invalid resource key: %s", key))
+		return nil
+	}
+	trigger, err := c.triggersLister.LotusTriggers(namespace).Get(name)
+	if errors.IsNotFound(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	workloads, err := c.listRolledOutWorkloads(trigger.Spec.Target)
+	if err != nil {
+		return err
+	}
+
+	triggerCopy := trigger.DeepCopy()
+	if triggerCopy.Status.ObservedImages == nil {
+		triggerCopy.Status.ObservedImages = make(map[string]string)
+	}
+	changed := false
+	for workload, rollout := range workloads {
+		image := rollout.image
+		previous, observed := triggerCopy.Status.ObservedImages[workload]
+		if previous == image {
+			continue
+		}
+		triggerCopy.Status.ObservedImages[workload] = image
+		changed = true
+		// Do not run the test for the images rolled out before the trigger was created.
+		if !observed {
+			continue
+		}
+		lotus, err := c.createLotus(trigger, workload, rollout)
+		if err != nil {
+			c.logger.Error("failed to create lotus", zap.String("trigger", key), zap.Error(err))
+			return err
+		}
+		now := metav1.Now()
+		triggerCopy.Status.LastTriggeredTime = &now
+		triggerCopy.Status.LastLotusName = lotus
+		c.logger.Info("triggered a lotus by a rollout",
+			zap.String("trigger", key),
+			zap.String("workload", workload),
+			zap.String("image", image),
+			zap.String("lotus", lotus))
+	}
+	if !changed {
+		return nil
+	}
+	_, err = c.lotusclientset.LotusV1beta1().LotusTriggers(namespace).Update(triggerCopy)
+	return err
+}
+
+// rollout is the last completed rollout of a workload.
+type rollout struct {
+	image string
+	// generation tells apart the rollouts of the same image,
+	// e.g. a rollback to a previously tested one.
+	generation int64
+}
+
+// listRolledOutWorkloads returns the last rollouts of the target workloads
+// which have completed them, keyed by "<kind>/<name>".
+func (c *TriggerController) listRolledOutWorkloads(target lotusv1beta1.LotusTriggerTarget) (map[string]rollout, error) {
+	selector, err := metav1.LabelSelectorAsSelector(target.Selector)
+	if err != nil {
+		return nil, err
+	}
+	workloads := make(map[string]rollout)
+	switch target.Kind {
+	case lotusv1beta1.LotusTriggerDeployment:
+		deployments, err := c.deploymentsLister.Deployments(target.Namespace).List(selector)
+		if err != nil {
+			return nil, err
+		}
+		for _, d := range deployments {
+			if image := podTemplateImage(&d.Spec.Template, target.Container); image != "" && deploymentRolledOut(d) {
+				workloads[fmt.Sprintf("%s/%s", target.Kind, d.Name)] = rollout{image: image, generation: d.Generation}
+			}
+		}
+	case lotusv1beta1.LotusTriggerStatefulSet:
+		statefulSets, err := c.statefulSetsLister.StatefulSets(target.Namespace).List(selector)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range statefulSets {
+			if image := podTemplateImage(&s.Spec.Template, target.Container); image != "" && statefulSetRolledOut(s) {
+				workloads[fmt.Sprintf("%s/%s", target.Kind, s.Name)] = rollout{image: image, generation: s.Generation}
+			}
+		}
+	default:
+		return nil, fmt.Errorf("unsupported target kind: %s", target.Kind)
+	}
+	return workloads, nil
+}
+
+// createLotus creates a Lotus from the template of the given trigger and returns its name.
+// The name is derived from the workload, the image and the generation of the rollout,
+// so that the same rollout never creates more than one Lotus while a rollback
+// to a previously tested image is tested again.
+func (c *TriggerController) createLotus(trigger *lotusv1beta1.LotusTrigger, workload string, r rollout) (string, error) {
+	lotus := newTriggeredLotus(trigger, workload, r)
+	_, err := c.lotusclientset.LotusV1beta1().Lotuses(lotus.Namespace).Create(lotus)
+	if err != nil && !errors.IsAlreadyExists(err) {
+		return "", err
+	}
+	return lotus.Name, nil
+}
+
+func newTriggeredLotus(trigger *lotusv1beta1.LotusTrigger, workload string, r rollout) *lotusv1beta1.Lotus {
+	template := trigger.Spec.Template.DeepCopy()
+	image := r.image
+	h := fnv.New32a()
+	h.Write([]byte(fmt.Sprintf("%s@%s#%d", workload, image, r.generation)))
+
+	lotus := &lotusv1beta1.Lotus{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        fmt.Sprintf("%s-%08x", trigger.Name, h.Sum32()),
+			Namespace:   trigger.Namespace,
+			Labels:      template.Labels,
+			Annotations: template.Annotations,
+		},
+		Spec: template.Spec,
+	}
+	if lotus.Labels == nil {
+		lotus.Labels = make(map[string]string)
+	}
+	lotus.Labels[TriggerLabel] = trigger.Name
+	if lotus.Annotations == nil {
+		lotus.Annotations = make(map[string]string)
+	}
+	lotus.Annotations[TriggerImageAnnotation] = image
+	lotus.Annotations[TriggerTargetAnnotation] = fmt.Sprintf("%s/%s", trigger.Spec.Target.Namespace, workload)
+	return lotus
+}
+
+// rolloutAnnotation returns the annotation of the rollout which created the given Lotus,
+// or nil if it was not created by a trigger.
+// It is added to the status when the Lotus is initialized, since the status is not set on create.
+func rolloutAnnotation(lotus *lotusv1beta1.Lotus) *lotusv1beta1.LotusAnnotation {
+	image, ok := lotus.Annotations[TriggerImageAnnotation]
+	if !ok || lotus.Labels[TriggerLabel] == "" {
+		return nil
+	}
+	return &lotusv1beta1.LotusAnnotation{
+		Time: lotus.CreationTimestamp,
+		Text: fmt.Sprintf("testing %s rolled out to %s", image, lotus.Annotations[TriggerTargetAnnotation]),
+	}
+}
+
+// podTemplateImage returns the image of the given container, or the images
+// of all containers joined in order of their names if the container is empty.
+func podTemplateImage(template *corev1.PodTemplateSpec, container string) string {
+	images := make([]string, 0, len(template.Spec.Containers))
+	for _, c := range template.Spec.Containers {
+		if container != "" {
+			if c.Name == container {
+				return c.Image
+			}
+			continue
+		}
+		images = append(images, fmt.Sprintf("%s=%s", c.Name, c.Image))
+	}
+	if container != "" {
+		return ""
+	}
+	if len(images) == 1 {
+		return template.Spec.Containers[0].Image
+	}
+	sort.Strings(images)
+	return strings.Join(images, ",")
+}
+
+func deploymentRolledOut(d *appsv1.Deployment) bool {
+	replicas := int32(1)
+	if d.Spec.Replicas != nil {
+		replicas = *d.Spec.Replicas
+	}
+	return d.Status.ObservedGeneration >= d.Generation &&
+		d.Status.UpdatedReplicas == replicas &&
+		d.Status.Replicas == replicas &&
+		d.Status.AvailableReplicas == replicas
+}
+
+func statefulSetRolledOut(s *appsv1.StatefulSet) bool {
+	replicas := int32(1)
+	if s.Spec.Replicas != nil {
+		replicas = *s.Spec.Replicas
+	}
+	return s.Status.ObservedGeneration >= s.Generation &&
+		s.Status.UpdateRevision == s.Status.CurrentRevision &&
+		s.Status.UpdatedReplicas == replicas &&
+		s.Status.ReadyReplicas == replicas
+}
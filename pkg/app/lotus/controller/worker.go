@@ -0,0 +1,142 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package controller
+
+import (
+	"fmt"
+	"strings"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	lotusv1beta1 "github.com/lotusload/lotus/pkg/app/lotus/apis/lotus/v1beta1"
+	"github.com/lotusload/lotus/pkg/app/lotus/resource"
+)
+
+// syncWorkerResources applies the current worker spec to the running worker
+// Deployment and Service, so that a running test can be re-scaled or reconfigured.
+// Every applied change is recorded as an event and as an annotation of the Lotus.
+func (c *Controller) syncWorkerResources(lotus *lotusv1beta1.Lotus) error {
+	factory := resource.NewFactory(lotus, c.configFile)
+	name := factory.WorkerName()
+	changes := make([]string, 0)
+
+	service, err := c.kubeClient.GetService(name, lotus.Namespace)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	serviceFound := err == nil
+	desiredService, err := factory.NewWorkerService()
+	if err != nil {
+		return err
+	}
+	if serviceFound {
+		changes = append(changes, serviceChanges(service, desiredService)...)
+	}
+	if _, err := c.kubeClient.ApplyService(name, lotus.Namespace, desiredService); err != nil {
+		return err
+	}
+
+	deployment, err := c.kubeClient.GetDeployment(name, lotus.Namespace)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+	deploymentFound := err == nil
+	desiredDeployment, err := factory.NewWorkerDeployment()
+	if err != nil {
+		return err
+	}
+	if deploymentFound {
+		changes = append(changes, deploymentChanges(deployment, desiredDeployment)...)
+	}
+	if _, err := c.kubeClient.ApplyDeployment(name, lotus.Namespace, desiredDeployment); err != nil {
+		return err
+	}
+
+	if len(changes) == 0 {
+		return nil
+	}
+	text := fmt.Sprintf("worker updated: %s", strings.Join(changes, ", "))
+	c.recorder.Event(lotus, corev1.EventTypeNormal, "WorkerUpdated", text)
+	return c.addAnnotation(lotus, text)
+}
+
+// addAnnotation appends an annotation to the status of the given Lotus and
+// re-renders the Prometheus rules to export it to the metrics timeline.
+// Only the latest resource.MaxAnnotations annotations are kept.
+func (c *Controller) addAnnotation(lotus *lotusv1beta1.Lotus, text string) error {
+	lotusCopy := lotus.DeepCopy()
+	annotations := append(lotusCopy.Status.Annotations, lotusv1beta1.LotusAnnotation{
+		Time: metav1.Now(),
+		Text: text,
+	})
+	if len(annotations) > resource.MaxAnnotations {
+		annotations = annotations[len(annotations)-resource.MaxAnnotations:]
+	}
+	lotusCopy.Status.Annotations = annotations
+	updated, err := c.lotusclientset.LotusV1beta1().Lotuses(lotus.Namespace).Update(lotusCopy)
+	if err != nil {
+		return err
+	}
+	factory := resource.NewFactory(updated, c.configFile)
+	configMap, err := factory.NewPrometheusConfigMap()
+	if err != nil {
+		return err
+	}
+	_, err = c.kubeClient.ApplyConfigMap(factory.PrometheusName(), lotus.Namespace, configMap)
+	return err
+}
+
+func deploymentChanges(current, desired *appsv1.Deployment) []string {
+	changes := make([]string, 0)
+	if from, to := replicas(current), replicas(desired); from != to {
+		changes = append(changes, fmt.Sprintf("replicas %d -> %d", from, to))
+	}
+	// Deployments created by an older controller do not have the hash.
+	hash, ok := current.Annotations[resource.WorkerTemplateHashAnnotation]
+	if ok && hash != desired.Annotations[resource.WorkerTemplateHashAnnotation] {
+		changes = append(changes, "pod template changed")
+	}
+	return changes
+}
+
+func serviceChanges(current, desired *corev1.Service) []string {
+	changes := make([]string, 0)
+	if len(current.Spec.Ports) != len(desired.Spec.Ports) {
+		return append(changes, "ports changed")
+	}
+	for i := range desired.Spec.Ports {
+		from, to := current.Spec.Ports[i], desired.Spec.Ports[i]
+		if from.Port != to.Port || from.TargetPort != to.TargetPort {
+			changes = append(changes, fmt.Sprintf("%s port %d -> %d", to.Name, from.Port, to.Port))
+		}
+	}
+	return changes
+}
+
+func replicas(d *appsv1.Deployment) int32 {
+	if d.Spec.Replicas == nil {
+		return 1
+	}
+	return *d.Spec.Replicas
+}
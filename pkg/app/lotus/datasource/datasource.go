@@ -22,14 +22,31 @@ package datasource
 
 import (
 	"context"
+	"fmt"
+	"regexp"
+	"strings"
 	"time"
 
+	prommodel "github.com/prometheus/common/model"
 	"go.uber.org/zap"
 
 	"github.com/lotusload/lotus/pkg/app/lotus/config"
 	"github.com/lotusload/lotus/pkg/app/lotus/model"
 )
 
+// RangePlaceholder in the expressions of assertions and summary metrics
+// is replaced by the duration of the test window.
+const RangePlaceholder = "$__range"
+
+// ExpandRange replaces RangePlaceholder in the expression with the given duration in seconds, at least 1s.
+func ExpandRange(expr string, d time.Duration) string {
+	seconds := int64(d / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	return strings.Replace(expr, RangePlaceholder, fmt.Sprintf("%ds", seconds), -1)
+}
+
 type Builder interface {
 	Build(ds *config.DataSource, opts BuildOptions) (DataSource, error)
 }
@@ -47,12 +64,27 @@ func (o BuildOptions) NamedLogger(name string) *zap.Logger {
 
 type DataSource interface {
 	Querier
-	Checker
 }
 
 type Querier interface {
 	Query(ctx context.Context, query string, ts time.Time) ([]*Sample, error)
-	CollectSummary(ctx context.Context, ts time.Time) (*model.MetricsSummary, error)
+	CollectSummary(ctx context.Context, q SummaryQuery) (*model.MetricsSummary, error)
+	CollectTimeSeries(ctx context.Context, q SummaryQuery) (*model.TimeSeries, error)
+	// CollectProgress returns the current request rate and error percentage of the test.
+	CollectProgress(ctx context.Context, q SummaryQuery) (rps, errorPercentage float64, err error)
+}
+
+// SummaryQuery selects the metrics collected into the summary of a test.
+type SummaryQuery struct {
+	TestID string
+	Start  time.Time
+	End    time.Time
+	// The quantiles of the latency percentiles, e.g. 0.99 for p99.
+	LatencyQuantiles []float64
+	// FailureCriteria decides which requests are counted as failures.
+	FailureCriteria *config.FailureCriteria
+	// Metrics are the user-defined queries included in the summary.
+	Metrics []*config.SummaryMetric
 }
 
 type Sample struct {
@@ -66,11 +98,61 @@ type Checker interface {
 }
 
 type Check struct {
-	Name string
-	Expr string
-	For  string
+	Name     string
+	Expr     string
+	For      string
+	Severity model.CheckSeverity
+}
+
+// lowerBoundRegex matches the expressions which are active while their value is below a trailing scalar,
+// such as the thresholds of lower bounds like "rps > 100" are translated into.
+var lowerBoundRegex = regexp.MustCompile(`(^|[^<>!=])<=?\s*(bool\s+)?[-+]?(\d+\.?\d*|\.\d+)([eE][-+]?\d+)?\s*$`)
+
+// LowerIsWorse reports whether the expression of the check is active while its value is below a threshold,
+// so that the lowest value is the worst one.
+func (c Check) LowerIsWorse() bool {
+	return lowerBoundRegex.MatchString(c.Expr)
+}
+
+// Worse reports whether the value is worse than the other one for the check.
+// A value is worse than NoDataValue, which is never worse than a value.
+func (c Check) Worse(value, other float64) bool {
+	switch {
+	case value == model.NoDataValue:
+		return false
+	case other == model.NoDataValue:
+		return true
+	case c.LowerIsWorse():
+		return value < other
+	default:
+		return value > other
+	}
+}
+
+// ForDuration returns how long the expression of the check
+// must be active before the check is firing.
+// It accepts the same format as the for clause of Prometheus alerting rules.
+func (c Check) ForDuration() (time.Duration, error) {
+	if c.For == "" {
+		return 0, nil
+	}
+	d, err := prommodel.ParseDuration(c.For)
+	if err != nil {
+		return 0, fmt.Errorf("invalid for of check %s: %v", c.Name, err)
+	}
+	return time.Duration(d), nil
 }
 
 type CheckResult struct {
+	// The names of the firing checks.
 	Actives []string
+	// The names of the checks which are active but not yet firing.
+	Pendings []string
+	// The outcome of each evaluated check.
+	Evaluations []CheckEvaluation
+}
+
+type CheckEvaluation struct {
+	Name string
+	model.CheckEvaluation
 }
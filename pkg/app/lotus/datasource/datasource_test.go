@@ -0,0 +1,53 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package datasource
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lotusload/lotus/pkg/app/lotus/model"
+)
+
+func TestExpandRange(t *testing.T) {
+	assert.Equal(t,
+		`histogram_quantile(0.99, sum by (le) (rate(latency_bucket[3600s])))`,
+		ExpandRange(`histogram_quantile(0.99, sum by (le) (rate(latency_bucket[$__range])))`, time.Hour+500*time.Millisecond),
+	)
+	assert.Equal(t, "increase(errors[1s])", ExpandRange("increase(errors[$__range])", 0))
+}
+
+func TestCheckWorse(t *testing.T) {
+	upper := Check{Expr: "histogram_quantile(0.99, sum by (le) (rate(latency_bucket[1m]))) >= 300"}
+	lower := Check{Expr: "sum(rate(requests[1m])) <= 1e2"}
+	assert.False(t, upper.LowerIsWorse())
+	assert.True(t, lower.LowerIsWorse())
+	assert.True(t, Check{Expr: "sum(up) < 3"}.LowerIsWorse())
+	assert.False(t, Check{Expr: "sum(up) != 3"}.LowerIsWorse())
+
+	assert.True(t, upper.Worse(400, 350))
+	assert.False(t, lower.Worse(400, 350))
+	assert.True(t, lower.Worse(50, 80))
+	assert.True(t, lower.Worse(50, model.NoDataValue))
+	assert.False(t, lower.Worse(model.NoDataValue, 80))
+}
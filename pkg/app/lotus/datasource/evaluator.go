@@ -0,0 +1,104 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package datasource
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/lotusload/lotus/pkg/app/lotus/model"
+)
+
+// Evaluator is a Checker which evaluates the expressions of checks
+// against a Querier and keeps track of how long each of them has been active,
+// in the same way as the alerting rules of Prometheus.
+// A check is pending while its expression returns any sample,
+// and becomes firing after it has been pending for the duration of its For.
+type Evaluator struct {
+	querier     Querier
+	activeSince map[string]time.Time
+	now         func() time.Time
+}
+
+func NewEvaluator(querier Querier) *Evaluator {
+	return &Evaluator{
+		querier:     querier,
+		activeSince: make(map[string]time.Time),
+		now:         time.Now,
+	}
+}
+
+func (e *Evaluator) Check(ctx context.Context, checks []Check) (*CheckResult, error) {
+	now := e.now()
+	result := &CheckResult{
+		Actives:     make([]string, 0),
+		Pendings:    make([]string, 0),
+		Evaluations: make([]CheckEvaluation, 0, len(checks)),
+	}
+	for _, check := range checks {
+		duration, err := check.ForDuration()
+		if err != nil {
+			return nil, err
+		}
+		samples, err := e.querier.Query(ctx, check.Expr, now)
+		if err != nil {
+			return nil, fmt.Errorf("failed to evaluate check %s: %v", check.Name, err)
+		}
+		evaluation := CheckEvaluation{
+			Name: check.Name,
+			CheckEvaluation: model.CheckEvaluation{
+				Timestamp: now,
+				State:     model.CheckInactive,
+				Value:     worstValue(check, samples),
+			},
+		}
+		if len(samples) == 0 {
+			delete(e.activeSince, check.Name)
+			result.Evaluations = append(result.Evaluations, evaluation)
+			continue
+		}
+		since, ok := e.activeSince[check.Name]
+		if !ok {
+			since = now
+			e.activeSince[check.Name] = now
+		}
+		if now.Sub(since) >= duration {
+			evaluation.State = model.CheckFiring
+			result.Actives = append(result.Actives, check.Name)
+		} else {
+			evaluation.State = model.CheckPending
+			result.Pendings = append(result.Pendings, check.Name)
+		}
+		result.Evaluations = append(result.Evaluations, evaluation)
+	}
+	return result, nil
+}
+
+func worstValue(check Check, samples []*Sample) float64 {
+	value := model.NoDataValue
+	for i, s := range samples {
+		if i == 0 || check.Worse(s.Value, value) {
+			value = s.Value
+		}
+	}
+	return value
+}
@@ -0,0 +1,104 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package datasource
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/lotusload/lotus/pkg/app/lotus/model"
+)
+
+type fakeQuerier struct {
+	samples map[string][]*Sample
+}
+
+func (q *fakeQuerier) Query(ctx context.Context, query string, ts time.Time) ([]*Sample, error) {
+	return q.samples[query], nil
+}
+
+func (q *fakeQuerier) CollectTimeSeries(ctx context.Context, sq SummaryQuery) (*model.TimeSeries, error) {
+	return nil, nil
+}
+
+func (q *fakeQuerier) CollectProgress(ctx context.Context, sq SummaryQuery) (float64, float64, error) {
+	return 0, 0, nil
+}
+
+func (q *fakeQuerier) CollectSummary(ctx context.Context, sq SummaryQuery) (*model.MetricsSummary, error) {
+	return nil, nil
+}
+
+func TestEvaluator(t *testing.T) {
+	querier := &fakeQuerier{samples: make(map[string][]*Sample)}
+	now := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	evaluator := NewEvaluator(querier)
+	evaluator.now = func() time.Time { return now }
+	checks := []Check{
+		Check{Name: "NoWorker", Expr: "absent(up)", For: "30s"},
+		Check{Name: "HasWorkerDown", Expr: "up == 0", For: "1m"},
+	}
+	active := []*Sample{&Sample{Value: 1}}
+
+	result, err := evaluator.Check(context.Background(), checks)
+	require.NoError(t, err)
+	assert.Empty(t, result.Actives)
+	assert.Empty(t, result.Pendings)
+
+	querier.samples["absent(up)"] = active
+	result, err = evaluator.Check(context.Background(), checks)
+	require.NoError(t, err)
+	assert.Empty(t, result.Actives)
+	assert.Equal(t, []string{"NoWorker"}, result.Pendings)
+
+	now = now.Add(30 * time.Second)
+	querier.samples["up == 0"] = []*Sample{&Sample{Value: 0}, &Sample{Value: 2}}
+	result, err = evaluator.Check(context.Background(), checks)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"NoWorker"}, result.Actives)
+	assert.Equal(t, []string{"HasWorkerDown"}, result.Pendings)
+	assert.Equal(t, []CheckEvaluation{
+		CheckEvaluation{Name: "NoWorker", CheckEvaluation: model.CheckEvaluation{Timestamp: now, State: model.CheckFiring, Value: 1}},
+		CheckEvaluation{Name: "HasWorkerDown", CheckEvaluation: model.CheckEvaluation{Timestamp: now, State: model.CheckPending, Value: 2}},
+	}, result.Evaluations)
+
+	// The check becomes inactive as soon as its expression returns no sample.
+	now = now.Add(30 * time.Second)
+	delete(querier.samples, "absent(up)")
+	result, err = evaluator.Check(context.Background(), checks)
+	require.NoError(t, err)
+	assert.Empty(t, result.Actives)
+	assert.Equal(t, []string{"HasWorkerDown"}, result.Pendings)
+
+	now = now.Add(30 * time.Second)
+	querier.samples["absent(up)"] = active
+	result, err = evaluator.Check(context.Background(), checks)
+	require.NoError(t, err)
+	assert.Equal(t, []string{"HasWorkerDown"}, result.Actives)
+	assert.Equal(t, []string{"NoWorker"}, result.Pendings)
+
+	_, err = evaluator.Check(context.Background(), []Check{Check{Name: "Invalid", Expr: "up", For: "30"}})
+	assert.Error(t, err)
+}
@@ -23,6 +23,8 @@ package prometheus
 import (
 	"context"
 	"fmt"
+	"math"
+	"sort"
 	"strings"
 	"time"
 
@@ -30,18 +32,13 @@ import (
 	prommodel "github.com/prometheus/common/model"
 	"go.uber.org/zap"
 
+	"github.com/lotusload/lotus/pkg/app/lotus/config"
 	"github.com/lotusload/lotus/pkg/app/lotus/datasource"
 	"github.com/lotusload/lotus/pkg/app/lotus/model"
 	"github.com/lotusload/lotus/pkg/metrics/grpcmetrics"
 	"github.com/lotusload/lotus/pkg/metrics/httpmetrics"
 )
 
-const (
-	AlertNameLabel   = prommodel.AlertNameLabel
-	AlertStateLabel  = "alertstate"
-	AlertStateFiring = "firing"
-)
-
 type prometheus struct {
 	api    promv1.API
 	logger *zap.Logger
@@ -52,11 +49,20 @@ func (p *prometheus) Query(ctx context.Context, query string, ts time.Time) ([]*
 	if err != nil {
 		return nil, err
 	}
-	vector, ok := v.(prommodel.Vector)
-	if !ok {
+	switch value := v.(type) {
+	case prommodel.Vector:
+		return vectorToSamples(value), nil
+	case *prommodel.Scalar:
+		return []*datasource.Sample{
+			&datasource.Sample{
+				Labels:    map[string]string{},
+				Value:     float64(value.Value),
+				Timestamp: value.Timestamp.Time(),
+			},
+		}, nil
+	default:
 		return nil, fmt.Errorf("unsupported value type: %s, %v", v.Type(), v)
 	}
-	return vectorToSamples(vector), nil
 }
 
 func vectorToSamples(vector prommodel.Vector) []*datasource.Sample {
@@ -75,106 +81,84 @@ func vectorToSamples(vector prommodel.Vector) []*datasource.Sample {
 	return samples
 }
 
-func (p *prometheus) Check(ctx context.Context, checks []datasource.Check) (*datasource.CheckResult, error) {
-	var ts time.Time
-	v, _, err := p.api.Query(ctx, "ALERTS", ts)
+// CollectSummary collects the metrics of the given test during its window.
+// All queries are evaluated at the end of the window.
+func (p *prometheus) CollectSummary(ctx context.Context, q datasource.SummaryQuery) (*model.MetricsSummary, error) {
+	ts := q.End
+	sq := newSummaryQueries(q)
+	grpcByMethod, err := p.collectGRPCByMethod(ctx, sq, ts)
 	if err != nil {
-		p.logger.Error("failed to run query to get alerts", zap.Error(err))
 		return nil, err
 	}
-	vector, ok := v.(prommodel.Vector)
-	if !ok {
-		p.logger.Error("unsupported value type", zap.Any("value", v))
-		return nil, fmt.Errorf("unsupported value type: %s", v.Type())
+	httpByPath, err := p.collectHTTPByPath(ctx, sq, ts)
+	if err != nil {
+		return nil, err
 	}
-	p.logger.Debug("extracting actives", zap.Any("vector", vector), zap.Any("checks", checks))
-	return &datasource.CheckResult{
-		Actives: extractActives(vector, checks),
-	}, nil
-}
-
-func extractActives(vector prommodel.Vector, checks []datasource.Check) []string {
-	targets := make(map[string]struct{}, len(checks))
-	for _, check := range checks {
-		targets[check.Name] = struct{}{}
-	}
-	actives := make(map[string]struct{})
-	for _, sample := range vector {
-		if sample.Value == 0 {
-			continue
-		}
-		name, ok := sample.Metric[AlertNameLabel]
-		if !ok {
-			continue
-		}
-		if _, ok := targets[string(name)]; !ok {
-			continue
-		}
-		state, ok := sample.Metric[AlertStateLabel]
-		if !ok {
-			continue
-		}
-		if state != AlertStateFiring {
-			continue
-		}
-		actives[string(name)] = struct{}{}
+	grpcAll, err := p.multiQuery(ctx, sq.grpc(""), ts)
+	if err != nil {
+		return nil, err
 	}
-	list := make([]string, 0, len(actives))
-	for k, _ := range actives {
-		list = append(list, k)
+	httpAll, err := p.multiQuery(ctx, sq.http(""), ts)
+	if err != nil {
+		return nil, err
 	}
-	return list
-}
-
-func (p *prometheus) CollectSummary(ctx context.Context, ts time.Time) (*model.MetricsSummary, error) {
-	grpcByMethod, err := p.collectGRPCByMethod(ctx, ts)
+	rpsQueries := []struct {
+		Query  string
+		ToKey  labelsToKey
+		Target map[string]model.ValueByLabel
+	}{
+		{Query: sq.grpcRPS(grpcMethodLabel), ToKey: grpcMethodKey, Target: grpcByMethod},
+		{Query: sq.grpcRPS(""), ToKey: allKey, Target: map[string]model.ValueByLabel{"": grpcAll}},
+		{Query: sq.httpRPS(httpPathLabels), ToKey: httpPathKey, Target: httpByPath},
+		{Query: sq.httpRPS(""), ToKey: allKey, Target: map[string]model.ValueByLabel{"": httpAll}},
+	}
+	for _, rq := range rpsQueries {
+		if err := p.collectRPS(ctx, sq, rq.Query, rq.ToKey, rq.Target); err != nil {
+			return nil, err
+		}
+	}
+	grpcStatusCounts, err := p.collectStatusCounts(ctx, sq.grpcStatusCounts(""), allKey, grpcStatusLabel, ts)
 	if err != nil {
 		return nil, err
 	}
-	httpByPath, err := p.collectHTTPByPath(ctx, ts)
+	grpcStatusCountsByMethod, err := p.collectStatusCounts(ctx, sq.grpcStatusCounts(grpcMethodLabel), grpcMethodKey, grpcStatusLabel, ts)
 	if err != nil {
 		return nil, err
 	}
-	grpcAll, err := p.multiQuery(ctx, map[string]string{
-		model.GRPCRPCsKey:              grpcRPCTotalQuery,
-		model.GRPCFailurePercentageKey: grpcFailurePercentageQuery,
-		model.GRPCLatencyAvgKey:        grpcLatencyAvgQuery,
-		model.GRPCSentBytesAvgKey:      grpcSentBytesAvgQuery,
-		model.GRPCReceivedBytesAvgKey:  grpcReceivedBytesAvgQuery,
-	}, ts)
+	httpStatusCounts, err := p.collectStatusCounts(ctx, sq.httpStatusCounts(""), allKey, httpStatusLabel, ts)
 	if err != nil {
 		return nil, err
 	}
-	httpAll, err := p.multiQuery(ctx, map[string]string{
-		model.HTTPRequestsKey:          httpRequestTotalQuery,
-		model.HTTPFailurePercentageKey: httpFailurePercentageQuery,
-		model.HTTPLatencyAvgKey:        httpLatencyAvgQuery,
-		model.HTTPSentBytesAvgKey:      httpSentBytesAvgQuery,
-		model.HTTPReceivedBytesAvgKey:  httpReceivedBytesAvgQuery,
-	}, ts)
+	httpStatusCountsByPath, err := p.collectStatusCounts(ctx, sq.httpStatusCounts(httpPathLabels), httpPathKey, httpStatusLabel, ts)
 	if err != nil {
 		return nil, err
 	}
 	summary := &model.MetricsSummary{
-		GRPCRPCTotal:          grpcAll[model.GRPCRPCsKey],
-		GRPCFailurePercentage: grpcAll[model.GRPCFailurePercentageKey],
-		GRPCAll:               grpcAll,
-		GRPCByMethod:          grpcByMethod,
-		HTTPRequestTotal:      httpAll[model.HTTPRequestsKey],
-		HTTPFailurePercentage: httpAll[model.HTTPFailurePercentageKey],
-		HTTPAll:               httpAll,
-		HTTPByPath:            httpByPath,
+		GRPCRPCTotal:             grpcAll[model.GRPCRPCsKey],
+		GRPCFailurePercentage:    grpcAll[model.GRPCFailurePercentageKey],
+		GRPCAll:                  grpcAll,
+		GRPCByMethod:             grpcByMethod,
+		GRPCStatusCounts:         grpcStatusCounts[""],
+		GRPCStatusCountsByMethod: grpcStatusCountsByMethod,
+		HTTPRequestTotal:         httpAll[model.HTTPRequestsKey],
+		HTTPFailurePercentage:    httpAll[model.HTTPFailurePercentageKey],
+		HTTPAll:                  httpAll,
+		HTTPByPath:               httpByPath,
+		HTTPStatusCounts:         httpStatusCounts[""],
+		HTTPStatusCountsByPath:   httpStatusCountsByPath,
+		LatencyQuantiles:         q.LatencyQuantiles,
+		CustomMetrics:            p.collectCustomMetrics(ctx, sq, q.Metrics, ts),
 	}
 	queries := []struct {
 		Query  string
 		Target *float64
 	}{
 		{
-			Query:  vuStartedTotalQuery,
+			Query:  sq.vuStartedTotal(),
 			Target: &summary.VirtualUserStartedTotal,
 		},
 		{
-			Query:  vuFailedTotalQuery,
+			Query:  sq.vuFailedTotal(),
 			Target: &summary.VirtualUserFailedTotal,
 		},
 	}
@@ -188,25 +172,37 @@ func (p *prometheus) CollectSummary(ctx context.Context, ts time.Time) (*model.M
 	return summary, nil
 }
 
-func (p *prometheus) collectGRPCByMethod(ctx context.Context, ts time.Time) (map[string]model.ValueByLabel, error) {
+// collectCustomMetrics evaluates the user-defined metrics.
+// A failed query is recorded in its metric instead of failing the whole summary.
+func (p *prometheus) collectCustomMetrics(ctx context.Context, sq summaryQueries, metrics []*config.SummaryMetric, ts time.Time) []model.CustomMetric {
+	if len(metrics) == 0 {
+		return nil
+	}
+	results := make([]model.CustomMetric, 0, len(metrics))
+	for _, m := range metrics {
+		result := model.CustomMetric{
+			Name:    m.Name,
+			GroupBy: m.GroupBy,
+		}
+		var err error
+		if len(m.GroupBy) == 0 {
+			result.Value, err = p.queryOne(ctx, sq.custom(m.Expr), ts)
+		} else {
+			result.Groups, err = p.queryByLabel(ctx, groupKey(m.GroupBy), sq.custom(m.Expr), ts)
+		}
+		if err != nil {
+			p.logger.Warn("failed to collect custom metric", zap.String("metric", m.Name), zap.Error(err))
+			result.Value, result.Groups, result.Error = model.NoDataValue, nil, err.Error()
+		}
+		results = append(results, result)
+	}
+	return results
+}
+
+func (p *prometheus) collectGRPCByMethod(ctx context.Context, sq summaryQueries, ts time.Time) (map[string]model.ValueByLabel, error) {
 	result := make(map[string]model.ValueByLabel)
-	queries := map[string]string{
-		model.GRPCRPCsKey:              grpcRPCsByMethodQuery,
-		model.GRPCFailurePercentageKey: grpcFailurePercentageByMethodQuery,
-		model.GRPCLatencyAvgKey:        grpcLatencyAvgByMethodQuery,
-		model.GRPCSentBytesAvgKey:      grpcSentBytesAvgByMethodQuery,
-		model.GRPCReceivedBytesAvgKey:  grpcReceivedBytesAvgByMethodQuery,
-	}
-	for name, query := range queries {
-		values, err := p.queryByLabel(
-			ctx,
-			func(labels map[string]string) (string, bool) {
-				value, ok := labels[grpcmetrics.KeyClientMethod.Name()]
-				return value, ok
-			},
-			query,
-			ts,
-		)
+	for name, query := range sq.grpc(grpcMethodLabel) {
+		values, err := p.queryByLabel(ctx, grpcMethodKey, query, ts)
 		if err != nil {
 			return nil, err
 		}
@@ -220,36 +216,10 @@ func (p *prometheus) collectGRPCByMethod(ctx context.Context, ts time.Time) (map
 	return result, nil
 }
 
-func (p *prometheus) collectHTTPByPath(ctx context.Context, ts time.Time) (map[string]model.ValueByLabel, error) {
+func (p *prometheus) collectHTTPByPath(ctx context.Context, sq summaryQueries, ts time.Time) (map[string]model.ValueByLabel, error) {
 	result := make(map[string]model.ValueByLabel)
-	queries := map[string]string{
-		model.HTTPRequestsKey:          httpRequestsByPathQuery,
-		model.HTTPFailurePercentageKey: httpFailurePercentageByPathQuery,
-		model.HTTPLatencyAvgKey:        httpLatencyAvgByPathQuery,
-		model.HTTPSentBytesAvgKey:      httpSentBytesAvgByPathQuery,
-		model.HTTPReceivedBytesAvgKey:  httpReceivedBytesAvgByPathQuery,
-	}
-	for name, query := range queries {
-		values, err := p.queryByLabel(
-			ctx,
-			func(labels map[string]string) (string, bool) {
-				host, ok := labels[httpmetrics.KeyClientHost.Name()]
-				if !ok {
-					return "", false
-				}
-				route, ok := labels[httpmetrics.KeyClientRoute.Name()]
-				if !ok {
-					return "", false
-				}
-				method, ok := labels[httpmetrics.KeyClientMethod.Name()]
-				if !ok {
-					return "", false
-				}
-				return fmt.Sprintf("%s/%s/%s", method, host, strings.TrimLeft(route, "/")), true
-			},
-			query,
-			ts,
-		)
+	for name, query := range sq.http(httpPathLabels) {
+		values, err := p.queryByLabel(ctx, httpPathKey, query, ts)
 		if err != nil {
 			return nil, err
 		}
@@ -263,6 +233,182 @@ func (p *prometheus) collectHTTPByPath(ctx context.Context, ts time.Time) (map[s
 	return result, nil
 }
 
+// collectStatusCounts returns the counts of the given query keyed by the row and then by the status label.
+func (p *prometheus) collectStatusCounts(ctx context.Context, query string, toKey labelsToKey, statusLabel string, ts time.Time) (map[string]model.ValueByLabel, error) {
+	samples, err := p.Query(ctx, query, ts)
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string]model.ValueByLabel)
+	for _, sample := range samples {
+		key, ok := toKey(sample.Labels)
+		if !ok {
+			continue
+		}
+		status, ok := sample.Labels[statusLabel]
+		if !ok {
+			continue
+		}
+		if _, ok := result[key]; !ok {
+			result[key] = make(map[string]float64)
+		}
+		result[key][status] = summaryValue(sample.Value)
+	}
+	return result, nil
+}
+
+// collectRPS computes the average and peak of the given rate over the test window
+// and stores them into the values of the matching label.
+func (p *prometheus) collectRPS(ctx context.Context, sq summaryQueries, query string, toKey labelsToKey, target map[string]model.ValueByLabel) error {
+	series, err := p.queryRange(ctx, query, sq)
+	if err != nil {
+		return err
+	}
+	for _, s := range series {
+		key, ok := toKey(s.Labels)
+		if !ok {
+			continue
+		}
+		values, ok := target[key]
+		if !ok {
+			continue
+		}
+		values[model.RPSAvgKey], values[model.RPSPeakKey] = averageAndPeak(s.Values)
+	}
+	return nil
+}
+
+func averageAndPeak(values []float64) (float64, float64) {
+	if len(values) == 0 {
+		return model.NoDataValue, model.NoDataValue
+	}
+	var sum, peak float64
+	for _, v := range values {
+		sum += v
+		if v > peak {
+			peak = v
+		}
+	}
+	return sum / float64(len(values)), peak
+}
+
+// CollectProgress returns the request rate and the error percentage of the test over the last minute before q.End.
+func (p *prometheus) CollectProgress(ctx context.Context, q datasource.SummaryQuery) (float64, float64, error) {
+	sq := newSummaryQueries(q)
+	rps, err := p.queryOne(ctx, sq.progressRPS(), q.End)
+	if err != nil {
+		return model.NoDataValue, model.NoDataValue, err
+	}
+	// The error percentage is NaN while no request has been sent.
+	errorPercentage, err := p.queryOne(ctx, sq.progressErrorPercentage(), q.End)
+	if err != nil {
+		return rps, model.NoDataValue, err
+	}
+	return rps, errorPercentage, nil
+}
+
+// CollectTimeSeries collects the downsampled rate, error percentage and p99 latency
+// of gRPC and HTTP over the test window.
+func (p *prometheus) CollectTimeSeries(ctx context.Context, q datasource.SummaryQuery) (*model.TimeSeries, error) {
+	sq := newSummaryQueries(q)
+	grpc, err := p.collectTimeSeriesPoints(ctx, sq, sq.grpcRPS(""), sq.grpcErrorPercentage(), sq.grpcLatencyP99())
+	if err != nil {
+		return nil, err
+	}
+	http, err := p.collectTimeSeriesPoints(ctx, sq, sq.httpRPS(""), sq.httpErrorPercentage(), sq.httpLatencyP99())
+	if err != nil {
+		return nil, err
+	}
+	return &model.TimeSeries{
+		Step: sq.step,
+		GRPC: grpc,
+		HTTP: http,
+	}, nil
+}
+
+func (p *prometheus) collectTimeSeriesPoints(ctx context.Context, sq summaryQueries, rps, errorPercentage, latencyP99 string) ([]model.TimeSeriesPoint, error) {
+	points := make(map[int64]*model.TimeSeriesPoint)
+	queries := []struct {
+		Query  string
+		Target func(point *model.TimeSeriesPoint) *float64
+	}{
+		{Query: rps, Target: func(point *model.TimeSeriesPoint) *float64 { return &point.RPS }},
+		{Query: errorPercentage, Target: func(point *model.TimeSeriesPoint) *float64 { return &point.ErrorPercentage }},
+		{Query: latencyP99, Target: func(point *model.TimeSeriesPoint) *float64 { return &point.LatencyP99 }},
+	}
+	for _, q := range queries {
+		series, err := p.queryRange(ctx, q.Query, sq)
+		if err != nil {
+			return nil, err
+		}
+		for _, s := range series {
+			for i, ts := range s.Timestamps {
+				point, ok := points[ts.Unix()]
+				if !ok {
+					point = &model.TimeSeriesPoint{
+						Timestamp:       ts,
+						RPS:             model.NoDataValue,
+						ErrorPercentage: model.NoDataValue,
+						LatencyP99:      model.NoDataValue,
+					}
+					points[ts.Unix()] = point
+				}
+				*q.Target(point) = s.Values[i]
+			}
+		}
+	}
+	result := make([]model.TimeSeriesPoint, 0, len(points))
+	for _, point := range points {
+		result = append(result, *point)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Timestamp.Before(result[j].Timestamp)
+	})
+	return result, nil
+}
+
+type rangeSeries struct {
+	Labels     map[string]string
+	Timestamps []time.Time
+	Values     []float64
+}
+
+// queryRange evaluates the given query over the test window at every step.
+// NaN values, e.g. the error percentage while no request was sent, are dropped.
+func (p *prometheus) queryRange(ctx context.Context, query string, sq summaryQueries) ([]*rangeSeries, error) {
+	v, _, err := p.api.QueryRange(ctx, query, promv1.Range{
+		Start: sq.start,
+		End:   sq.end,
+		Step:  sq.step,
+	})
+	if err != nil {
+		return nil, err
+	}
+	matrix, ok := v.(prommodel.Matrix)
+	if !ok {
+		return nil, fmt.Errorf("unsupported value type: %s, %v", v.Type(), v)
+	}
+	series := make([]*rangeSeries, 0, len(matrix))
+	for _, stream := range matrix {
+		s := &rangeSeries{
+			Labels: make(map[string]string, len(stream.Metric)),
+		}
+		for k, v := range stream.Metric {
+			s.Labels[string(k)] = string(v)
+		}
+		for _, pair := range stream.Values {
+			value := float64(pair.Value)
+			if math.IsNaN(value) || math.IsInf(value, 0) {
+				continue
+			}
+			s.Timestamps = append(s.Timestamps, pair.Timestamp.Time())
+			s.Values = append(s.Values, value)
+		}
+		series = append(series, s)
+	}
+	return series, nil
+}
+
 func (p *prometheus) queryOne(ctx context.Context, query string, ts time.Time) (float64, error) {
 	samples, err := p.Query(ctx, query, ts)
 	if err != nil {
@@ -274,11 +420,57 @@ func (p *prometheus) queryOne(ctx context.Context, query string, ts time.Time) (
 	if len(samples) == 0 {
 		return model.NoDataValue, nil
 	}
-	return samples[0].Value, nil
+	return summaryValue(samples[0].Value), nil
+}
+
+// summaryValue maps NaN and Inf, e.g. a quantile of an empty histogram or a 0/0 percentage,
+// to NoDataValue since they cannot be marshaled into JSON.
+func summaryValue(value float64) float64 {
+	if math.IsNaN(value) || math.IsInf(value, 0) {
+		return model.NoDataValue
+	}
+	return value
 }
 
 type labelsToKey func(labels map[string]string) (string, bool)
 
+func grpcMethodKey(labels map[string]string) (string, bool) {
+	value, ok := labels[grpcmetrics.KeyClientMethod.Name()]
+	return value, ok
+}
+
+func httpPathKey(labels map[string]string) (string, bool) {
+	host, ok := labels[httpmetrics.KeyClientHost.Name()]
+	if !ok {
+		return "", false
+	}
+	route, ok := labels[httpmetrics.KeyClientRoute.Name()]
+	if !ok {
+		return "", false
+	}
+	method, ok := labels[httpmetrics.KeyClientMethod.Name()]
+	if !ok {
+		return "", false
+	}
+	return fmt.Sprintf("%s/%s/%s", method, host, strings.TrimLeft(route, "/")), true
+}
+
+// groupKey joins the values of the given labels, which may be missing in some series.
+func groupKey(labels []string) labelsToKey {
+	return func(values map[string]string) (string, bool) {
+		parts := make([]string, 0, len(labels))
+		for _, label := range labels {
+			parts = append(parts, values[label])
+		}
+		return strings.Join(parts, "/"), true
+	}
+}
+
+// allKey puts the single series of a query without grouping under the empty key.
+func allKey(labels map[string]string) (string, bool) {
+	return "", true
+}
+
 func (p *prometheus) queryByLabel(ctx context.Context, toKey labelsToKey, query string, ts time.Time) (map[string]float64, error) {
 	values := make(map[string]float64)
 	samples, err := p.Query(ctx, query, ts)
@@ -290,7 +482,7 @@ func (p *prometheus) queryByLabel(ctx context.Context, toKey labelsToKey, query
 		if !ok {
 			continue
 		}
-		values[key] = sample.Value
+		values[key] = summaryValue(sample.Value)
 	}
 	return values, nil
 }
@@ -20,12 +20,88 @@
 
 package prometheus
 
-import "testing"
+import (
+	"context"
+	"math"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/api"
+	promv1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	prommodel "github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"go.uber.org/zap"
+
+	"github.com/lotusload/lotus/pkg/app/lotus/datasource"
+	"github.com/lotusload/lotus/pkg/app/lotus/model"
+)
+
+// emptyHistogramAPI answers every instant query with NaN, as Prometheus does
+// for the quantiles of a histogram without any observation, and every range query with no data.
+type emptyHistogramAPI struct {
+	promv1.API
+}
+
+func (emptyHistogramAPI) Query(ctx context.Context, query string, ts time.Time) (prommodel.Value, api.Warnings, error) {
+	return prommodel.Vector{
+		&prommodel.Sample{
+			Metric: prommodel.Metric{
+				grpcMethodLabel:      "helloworld.Greeter/SayHello",
+				grpcStatusLabel:      "OK",
+				"http_client_host":   "example.com",
+				"http_client_route":  "/users",
+				"http_client_method": "GET",
+				httpStatusLabel:      "200",
+			},
+			Value:     prommodel.SampleValue(math.NaN()),
+			Timestamp: prommodel.TimeFromUnixNano(ts.UnixNano()),
+		},
+	}, nil, nil
+}
+
+func (emptyHistogramAPI) QueryRange(ctx context.Context, query string, r promv1.Range) (prommodel.Value, api.Warnings, error) {
+	return prommodel.Matrix{}, nil, nil
+}
+
+func TestCollectSummaryEmptyHistogram(t *testing.T) {
+	p := &prometheus{api: emptyHistogramAPI{}, logger: zap.NewNop()}
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	summary, err := p.CollectSummary(context.Background(), datasource.SummaryQuery{
+		TestID:           "test-1",
+		Start:            start,
+		End:              start.Add(time.Minute),
+		LatencyQuantiles: []float64{0.99},
+	})
+	require.NoError(t, err)
+	assert.Equal(t, model.NoDataValue, summary.GRPCAll[model.LatencyPercentileKey(0.99)])
+	assert.Equal(t, model.NoDataValue, summary.HTTPFailurePercentage)
+	assert.Equal(t, model.NoDataValue, summary.GRPCByMethod["helloworld.Greeter/SayHello"][model.LatencyMaxKey])
+
+	result := &model.Result{TestID: "test-1", MetricsSummary: summary}
+	_, err = result.Render(model.RenderFormatJson)
+	assert.NoError(t, err)
+}
 
 func TestVectorToSamples(t *testing.T) {
 
 }
 
-func TestExtractActives(t *testing.T) {
+func TestAverageAndPeak(t *testing.T) {
+	avg, peak := averageAndPeak([]float64{10, 30, 20})
+	assert.Equal(t, 20.0, avg)
+	assert.Equal(t, 30.0, peak)
+
+	avg, peak = averageAndPeak(nil)
+	assert.Equal(t, model.NoDataValue, avg)
+	assert.Equal(t, model.NoDataValue, peak)
+}
+
+func TestGroupKey(t *testing.T) {
+	key, ok := groupKey([]string{"region", "tier"})(map[string]string{"region": "us", "tier": "gold", "job": "worker"})
+	assert.True(t, ok)
+	assert.Equal(t, "us/gold", key)
 
+	key, _ = groupKey([]string{"region", "tier"})(map[string]string{"tier": "gold"})
+	assert.Equal(t, "/gold", key)
 }
@@ -17,74 +17,244 @@
 // LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
 // OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
 // SOFTWARE.
-
 package prometheus
 
+import (
+	"fmt"
+	"time"
+
+	"github.com/lotusload/lotus/pkg/app/lotus/config"
+	"github.com/lotusload/lotus/pkg/app/lotus/datasource"
+	"github.com/lotusload/lotus/pkg/app/lotus/model"
+)
+
 const (
-	// VirtualUser Queries
-	vuStartedTotalQuery = `sum(max_over_time(lotus_virtual_user_count{virtual_user_status="started"}[1h]))`
+	// VirtualUser Metrics
+	vuCountMetric = "lotus_virtual_user_count"
 
-	vuFailedTotalQuery = `sum(max_over_time(lotus_virtual_user_count{virtual_user_status="failed"}[1h]))`
+	// GRPC Metrics
+	grpcCompletedRPCsMetric       = "lotus_grpc_client_completed_rpcs"
+	grpcRoundtripLatencyMetric    = "lotus_grpc_client_roundtrip_latency"
+	grpcSentBytesPerRPCMetric     = "lotus_grpc_client_sent_bytes_per_rpc"
+	grpcReceivedBytesPerRPCMetric = "lotus_grpc_client_received_bytes_per_rpc"
+	grpcMethodLabel               = "grpc_client_method"
+	grpcStatusLabel               = "grpc_client_status"
 
-	// GRPC Queries
-	grpcRPCTotalQuery = `sum(max_over_time(lotus_grpc_client_completed_rpcs[1h]))`
+	// HTTP Metrics
+	httpCompletedCountMetric   = "lotus_http_client_completed_count"
+	httpRoundtripLatencyMetric = "lotus_http_client_roundtrip_latency"
+	httpSentBytesMetric        = "lotus_http_client_sent_bytes"
+	httpReceivedBytesMetric    = "lotus_http_client_received_bytes"
+	httpPathLabels             = "http_client_host,http_client_route,http_client_method"
+	httpStatusLabel            = "http_client_status"
 
-	grpcFailurePercentageQuery = `100 * sum(max_over_time(lotus_grpc_client_completed_rpcs{grpc_client_status!~"OK|NOT_FOUND"}[1h])) /
-		sum(max_over_time(lotus_grpc_client_completed_rpcs[1h]))`
+	// Range queries
+	timeSeriesMaxPoints = 120
+	timeSeriesMinStep   = 15 * time.Second
+	minRateWindow       = time.Minute
 
-	grpcLatencyAvgQuery = `sum(max_over_time(lotus_grpc_client_roundtrip_latency_sum[1h])) /
-		sum(max_over_time(lotus_grpc_client_roundtrip_latency_count[1h]))`
+	// Progress queries
+	progressRateWindow = "1m"
 
-	grpcSentBytesAvgQuery = `sum(max_over_time(lotus_grpc_client_sent_bytes_per_rpc_sum[1h])) /
-		sum(max_over_time(lotus_grpc_client_sent_bytes_per_rpc_count[1h]))`
+	// The resolution of the totals is the scrape interval of the per-test Prometheus,
+	// so that the last sample of a series is not skipped.
+	totalResolution = "5s"
+)
 
-	grpcReceivedBytesAvgQuery = `sum(max_over_time(lotus_grpc_client_received_bytes_per_rpc_sum[1h])) /
-		sum(max_over_time(lotus_grpc_client_received_bytes_per_rpc_count[1h]))`
+// summaryQueries builds the queries of the metrics summary of a test.
+// All metrics are counters, so they are aggregated with increase()
+// over the exact window of the test and filtered by the test ID label.
+// The virtual user counts are totals of the test instead.
+//
+// The rates are evaluated by range queries over the same window at every step,
+// which downsamples long tests to at most timeSeriesMaxPoints points.
+type summaryQueries struct {
+	testID       string
+	window       string
+	quantiles    []float64
+	httpFailures []string
+	grpcFailures []string
+	start        time.Time
+	end          time.Time
+	step         time.Duration
+	rateWindow   string
+}
 
-	// GRPCByMethod Queries
-	grpcMethodLabel = "grpc_client_method"
+func newSummaryQueries(q datasource.SummaryQuery) summaryQueries {
+	seconds := int64(q.End.Sub(q.Start) / time.Second)
+	if seconds < 1 {
+		seconds = 1
+	}
+	step := (q.End.Sub(q.Start) / timeSeriesMaxPoints).Truncate(time.Second)
+	if step < timeSeriesMinStep {
+		step = timeSeriesMinStep
+	}
+	// The rate window must cover the whole step so that no request is skipped.
+	rateWindow := step
+	if rateWindow < minRateWindow {
+		rateWindow = minRateWindow
+	}
+	return summaryQueries{
+		testID:       q.TestID,
+		window:       fmt.Sprintf("%ds", seconds),
+		quantiles:    q.LatencyQuantiles,
+		httpFailures: q.FailureCriteria.HTTPFailureMatchers(),
+		grpcFailures: q.FailureCriteria.GRPCFailureMatchers(),
+		start:        q.Start,
+		end:          q.End,
+		step:         step,
+		rateWindow:   fmt.Sprintf("%ds", int64(rateWindow/time.Second)),
+	}
+}
 
-	grpcRPCsByMethodQuery = `sum by(grpc_client_method) (max_over_time(lotus_grpc_client_completed_rpcs[1h]))`
+// increase returns the increase of the given counter during the test,
+// summed by the given comma-separated labels.
+func (s summaryQueries) increase(by, metric, matcher string) string {
+	return s.aggregate(by, fmt.Sprintf("increase(%s{%s}[%s])", metric, s.selector(matcher), s.window))
+}
 
-	grpcFailurePercentageByMethodQuery = `100 * sum by(grpc_client_method) (max_over_time(lotus_grpc_client_completed_rpcs{grpc_client_status!~"OK|NOT_FOUND"}[1h])) /
-		sum by(grpc_client_method) (max_over_time(lotus_grpc_client_completed_rpcs[1h]))`
+// rate returns the per-second rate of the given counter over the rate window,
+// summed by the given comma-separated labels.
+func (s summaryQueries) rate(by, metric, matcher string) string {
+	return s.aggregate(by, fmt.Sprintf("rate(%s{%s}[%s])", metric, s.selector(matcher), s.rateWindow))
+}
 
-	grpcLatencyAvgByMethodQuery = `sum by(grpc_client_method) (max_over_time(lotus_grpc_client_roundtrip_latency_sum[1h])) /
-		sum by(grpc_client_method) (max_over_time(lotus_grpc_client_roundtrip_latency_count[1h]))`
+// failures applies the given function to the counter over the failed requests
+// selected by the disjoint matchers, summed by the given comma-separated labels.
+func (s summaryQueries) failures(by, fn, metric string, matchers []string, window string) string {
+	return s.aggregate(by, config.UnionOf(matchers, func(matcher string) string {
+		return fmt.Sprintf("%s(%s{%s}[%s])", fn, metric, s.selector(matcher), window)
+	}))
+}
 
-	grpcSentBytesAvgByMethodQuery = `sum by(grpc_client_method) (max_over_time(lotus_grpc_client_sent_bytes_per_rpc_sum[1h])) /
-		sum by(grpc_client_method) (max_over_time(lotus_grpc_client_sent_bytes_per_rpc_count[1h]))`
+func (s summaryQueries) selector(matcher string) string {
+	selector := fmt.Sprintf(`%s="%s"`, model.TestIDLabel, s.testID)
+	if matcher != "" {
+		selector = fmt.Sprintf("%s,%s", selector, matcher)
+	}
+	return selector
+}
 
-	grpcReceivedBytesAvgByMethodQuery = `sum by(grpc_client_method) (max_over_time(lotus_grpc_client_received_bytes_per_rpc_sum[1h])) /
-		sum by(grpc_client_method) (max_over_time(lotus_grpc_client_received_bytes_per_rpc_count[1h]))`
+func (s summaryQueries) aggregate(by, expr string) string {
+	if by == "" {
+		return fmt.Sprintf("sum(%s)", expr)
+	}
+	return fmt.Sprintf("sum by(%s) (%s)", by, expr)
+}
 
-	// HTTP Queries
-	httpRequestTotalQuery = `sum(max_over_time(lotus_http_client_completed_count[1h]))`
+func (s summaryQueries) failurePercentage(by, metric string, failures []string) string {
+	return fmt.Sprintf("100 * %s / %s", s.failures(by, "increase", metric, failures, s.window), s.increase(by, metric, ""))
+}
 
-	httpFailurePercentageQuery = `100 * sum(max_over_time(lotus_http_client_completed_count{http_client_status=~"5.."}[1h])) /
-		sum(max_over_time(lotus_http_client_completed_count[1h]))`
+func (s summaryQueries) average(by, metric string) string {
+	return fmt.Sprintf("%s / %s", s.increase(by, metric+"_sum", ""), s.increase(by, metric+"_count", ""))
+}
 
-	httpLatencyAvgQuery = `sum(max_over_time(lotus_http_client_roundtrip_latency_sum[1h])) /
-		sum(max_over_time(lotus_http_client_roundtrip_latency_count[1h]))`
+// latency returns the latency percentiles and max computed from the given histogram, keyed by the summary key.
+// The max is the upper bound of the highest non-empty bucket.
+func (s summaryQueries) latency(by, metric string) map[string]string {
+	buckets := s.increase(withLabel("le", by), metric+"_bucket", "")
+	queries := make(map[string]string, len(s.quantiles)+1)
+	for _, q := range s.quantiles {
+		queries[model.LatencyPercentileKey(q)] = fmt.Sprintf("histogram_quantile(%g, %s)", q, buckets)
+	}
+	queries[model.LatencyMaxKey] = fmt.Sprintf("histogram_quantile(1, %s)", buckets)
+	return queries
+}
 
-	httpSentBytesAvgQuery = `sum(max_over_time(lotus_http_client_sent_bytes_sum[1h])) /
-		sum(max_over_time(lotus_http_client_sent_bytes_count[1h]))`
+// grpcStatusCounts returns the number of completed RPCs summed by the status and the given labels.
+func (s summaryQueries) grpcStatusCounts(by string) string {
+	return s.increase(withLabel(by, grpcStatusLabel), grpcCompletedRPCsMetric, "")
+}
 
-	httpReceivedBytesAvgQuery = `sum(max_over_time(lotus_http_client_received_bytes_sum[1h])) /
-		sum(max_over_time(lotus_http_client_received_bytes_count[1h]))`
+// httpStatusCounts returns the number of completed requests summed by the status and the given labels.
+func (s summaryQueries) httpStatusCounts(by string) string {
+	return s.increase(withLabel(by, httpStatusLabel), httpCompletedCountMetric, "")
+}
 
-	// HTTPByPath Queries
-	httpRequestsByPathQuery = `sum by(http_client_host,http_client_route,http_client_method) (max_over_time(lotus_http_client_completed_count[1h]))`
+func withLabel(by, label string) string {
+	if by == "" {
+		return label
+	}
+	return fmt.Sprintf("%s,%s", by, label)
+}
 
-	httpFailurePercentageByPathQuery = `100 * sum by(http_client_host,http_client_route,http_client_method) (max_over_time(lotus_http_client_completed_count{http_client_status=~"5.."}[1h])) /
-		sum by(http_client_host,http_client_route,http_client_method) (max_over_time(lotus_http_client_completed_count[1h]))`
+func (s summaryQueries) grpcRPS(by string) string {
+	return s.rate(by, grpcCompletedRPCsMetric, "")
+}
 
-	httpLatencyAvgByPathQuery = `sum by(http_client_host,http_client_route,http_client_method) (max_over_time(lotus_http_client_roundtrip_latency_sum[1h])) /
-		sum by(http_client_host,http_client_route,http_client_method) (max_over_time(lotus_http_client_roundtrip_latency_count[1h]))`
+func (s summaryQueries) grpcErrorPercentage() string {
+	return fmt.Sprintf("100 * %s / %s", s.failures("", "rate", grpcCompletedRPCsMetric, s.grpcFailures, s.rateWindow), s.grpcRPS(""))
+}
 
-	httpSentBytesAvgByPathQuery = `sum by(http_client_host,http_client_route,http_client_method) (max_over_time(lotus_http_client_sent_bytes_sum[1h])) /
-		sum by(http_client_host,http_client_route,http_client_method) (max_over_time(lotus_http_client_sent_bytes_count[1h]))`
+func (s summaryQueries) grpcLatencyP99() string {
+	return fmt.Sprintf("histogram_quantile(0.99, %s)", s.rate("le", grpcRoundtripLatencyMetric+"_bucket", ""))
+}
 
-	httpReceivedBytesAvgByPathQuery = `sum by(http_client_host,http_client_route,http_client_method) (max_over_time(lotus_http_client_received_bytes_sum[1h])) /
-		sum by(http_client_host,http_client_route,http_client_method) (max_over_time(lotus_http_client_received_bytes_count[1h]))`
-)
+func (s summaryQueries) httpRPS(by string) string {
+	return s.rate(by, httpCompletedCountMetric, "")
+}
+
+func (s summaryQueries) httpErrorPercentage() string {
+	return fmt.Sprintf("100 * %s / %s", s.failures("", "rate", httpCompletedCountMetric, s.httpFailures, s.rateWindow), s.httpRPS(""))
+}
+
+func (s summaryQueries) httpLatencyP99() string {
+	return fmt.Sprintf("histogram_quantile(0.99, %s)", s.rate("le", httpRoundtripLatencyMetric+"_bucket", ""))
+}
+
+// progressRPS returns the current rate of the requests and RPCs of the test.
+func (s summaryQueries) progressRPS() string {
+	return fmt.Sprintf("(%s or vector(0)) + (%s or vector(0))",
+		s.aggregate("", fmt.Sprintf("rate(%s{%s}[%s])", httpCompletedCountMetric, s.selector(""), progressRateWindow)),
+		s.aggregate("", fmt.Sprintf("rate(%s{%s}[%s])", grpcCompletedRPCsMetric, s.selector(""), progressRateWindow)))
+}
+
+// progressErrorPercentage returns the current percentage of the requests and RPCs of the test which are failures.
+func (s summaryQueries) progressErrorPercentage() string {
+	return fmt.Sprintf("100 * ((%s or vector(0)) + (%s or vector(0))) / (%s)",
+		s.failures("", "rate", httpCompletedCountMetric, s.httpFailures, progressRateWindow),
+		s.failures("", "rate", grpcCompletedRPCsMetric, s.grpcFailures, progressRateWindow),
+		s.progressRPS())
+}
+
+// custom returns the expression of a user-defined metric evaluated over the test window.
+func (s summaryQueries) custom(expr string) string {
+	return datasource.ExpandRange(expr, s.end.Sub(s.start))
+}
+
+// total returns the total of the given cumulative count of the test, which is the max of its sum during the test.
+// Unlike increase(), it also counts what was recorded before the first scrape of a series.
+func (s summaryQueries) total(metric, matcher string) string {
+	return fmt.Sprintf("max_over_time(sum(%s{%s})[%s:%s])", metric, s.selector(matcher), s.window, totalResolution)
+}
+
+func (s summaryQueries) vuStartedTotal() string {
+	return s.total(vuCountMetric, `virtual_user_status="started"`)
+}
+
+func (s summaryQueries) vuFailedTotal() string {
+	return s.total(vuCountMetric, `virtual_user_status="failed"`)
+}
+
+// grpc returns the gRPC queries keyed by the summary key, summed by the given labels.
+func (s summaryQueries) grpc(by string) map[string]string {
+	queries := s.latency(by, grpcRoundtripLatencyMetric)
+	queries[model.GRPCRPCsKey] = s.increase(by, grpcCompletedRPCsMetric, "")
+	queries[model.GRPCFailurePercentageKey] = s.failurePercentage(by, grpcCompletedRPCsMetric, s.grpcFailures)
+	queries[model.GRPCLatencyAvgKey] = s.average(by, grpcRoundtripLatencyMetric)
+	queries[model.GRPCSentBytesAvgKey] = s.average(by, grpcSentBytesPerRPCMetric)
+	queries[model.GRPCReceivedBytesAvgKey] = s.average(by, grpcReceivedBytesPerRPCMetric)
+	return queries
+}
+
+// http returns the HTTP queries keyed by the summary key, summed by the given labels.
+func (s summaryQueries) http(by string) map[string]string {
+	queries := s.latency(by, httpRoundtripLatencyMetric)
+	queries[model.HTTPRequestsKey] = s.increase(by, httpCompletedCountMetric, "")
+	queries[model.HTTPFailurePercentageKey] = s.failurePercentage(by, httpCompletedCountMetric, s.httpFailures)
+	queries[model.HTTPLatencyAvgKey] = s.average(by, httpRoundtripLatencyMetric)
+	queries[model.HTTPSentBytesAvgKey] = s.average(by, httpSentBytesMetric)
+	queries[model.HTTPReceivedBytesAvgKey] = s.average(by, httpReceivedBytesMetric)
+	return queries
+}
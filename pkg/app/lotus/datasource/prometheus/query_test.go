@@ -0,0 +1,121 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package prometheus
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/lotusload/lotus/pkg/app/lotus/config"
+	"github.com/lotusload/lotus/pkg/app/lotus/datasource"
+	"github.com/lotusload/lotus/pkg/app/lotus/model"
+)
+
+func TestSummaryQueries(t *testing.T) {
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	sq := newSummaryQueries(datasource.SummaryQuery{
+		TestID:           "test-1",
+		Start:            start,
+		End:              start.Add(2*time.Hour + 500*time.Millisecond),
+		LatencyQuantiles: []float64{0.99},
+	})
+	// The virtual users counted before the first scrape of their series are included.
+	assert.Equal(t,
+		`max_over_time(sum(lotus_virtual_user_count{lotus_test_id="test-1",virtual_user_status="started"})[7200s:5s])`,
+		sq.vuStartedTotal())
+	assert.Equal(t,
+		`max_over_time(sum(lotus_virtual_user_count{lotus_test_id="test-1",virtual_user_status="failed"})[7200s:5s])`,
+		sq.vuFailedTotal())
+	assert.Equal(t,
+		`sum by(grpc_client_method) (increase(lotus_grpc_client_roundtrip_latency_sum{lotus_test_id="test-1"}[7200s])) / sum by(grpc_client_method) (increase(lotus_grpc_client_roundtrip_latency_count{lotus_test_id="test-1"}[7200s]))`,
+		sq.grpc(grpcMethodLabel)[model.GRPCLatencyAvgKey])
+	assert.Equal(t,
+		`100 * sum(increase(lotus_http_client_completed_count{lotus_test_id="test-1",http_client_status=~"5.."}[7200s])) / sum(increase(lotus_http_client_completed_count{lotus_test_id="test-1"}[7200s]))`,
+		sq.http("")[model.HTTPFailurePercentageKey])
+
+	assert.Equal(t,
+		`sum by(grpc_client_method,grpc_client_status) (increase(lotus_grpc_client_completed_rpcs{lotus_test_id="test-1"}[7200s]))`,
+		sq.grpcStatusCounts(grpcMethodLabel))
+	assert.Equal(t,
+		`sum by(http_client_status) (increase(lotus_http_client_completed_count{lotus_test_id="test-1"}[7200s]))`,
+		sq.httpStatusCounts(""))
+
+	latency := sq.http(httpPathLabels)
+	assert.Equal(t,
+		`histogram_quantile(0.99, sum by(le,http_client_host,http_client_route,http_client_method) (increase(lotus_http_client_roundtrip_latency_bucket{lotus_test_id="test-1"}[7200s])))`,
+		latency[model.LatencyPercentileKey(0.99)])
+	assert.Equal(t,
+		`histogram_quantile(1, sum by(le,http_client_host,http_client_route,http_client_method) (increase(lotus_http_client_roundtrip_latency_bucket{lotus_test_id="test-1"}[7200s])))`,
+		latency[model.LatencyMaxKey])
+
+	assert.Equal(t,
+		`sum(increase(orders_placed_total[7200s]))`,
+		sq.custom(`sum(increase(orders_placed_total[$__range]))`))
+
+	assert.Equal(t,
+		`(sum(rate(lotus_http_client_completed_count{lotus_test_id="test-1"}[1m])) or vector(0)) + (sum(rate(lotus_grpc_client_completed_rpcs{lotus_test_id="test-1"}[1m])) or vector(0))`,
+		sq.progressRPS())
+	assert.Contains(t, sq.progressErrorPercentage(),
+		`sum(rate(lotus_http_client_completed_count{lotus_test_id="test-1",http_client_status=~"5.."}[1m]))`)
+
+	sq = newSummaryQueries(datasource.SummaryQuery{Start: start, End: start})
+	assert.Equal(t, "1s", sq.window)
+}
+
+func TestSummaryQueriesFailureCriteria(t *testing.T) {
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	sq := newSummaryQueries(datasource.SummaryQuery{
+		TestID: "test-1",
+		Start:  start,
+		End:    start.Add(time.Minute),
+		FailureCriteria: &config.FailureCriteria{
+			GrpcCodes: []string{"INTERNAL", "UNAVAILABLE"},
+			Overrides: []*config.FailureOverride{{GrpcMethod: "users.Users/*", GrpcCodes: []string{"ALREADY_EXISTS"}}},
+		},
+	})
+	assert.Equal(t,
+		`100 * sum by(grpc_client_method) (increase(lotus_grpc_client_completed_rpcs{lotus_test_id="test-1",grpc_client_method=~"users\\.Users/.*",grpc_client_status=~"ALREADY_EXISTS"}[60s]) or increase(lotus_grpc_client_completed_rpcs{lotus_test_id="test-1",grpc_client_method!~"users\\.Users/.*",grpc_client_status=~"INTERNAL|UNAVAILABLE"}[60s])) / sum by(grpc_client_method) (increase(lotus_grpc_client_completed_rpcs{lotus_test_id="test-1"}[60s]))`,
+		sq.grpc(grpcMethodLabel)[model.GRPCFailurePercentageKey])
+}
+
+func TestSummaryQueriesStep(t *testing.T) {
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	sq := newSummaryQueries(datasource.SummaryQuery{
+		TestID: "test-1",
+		Start:  start,
+		End:    start.Add(10 * time.Minute),
+	})
+	assert.Equal(t, 15*time.Second, sq.step)
+	assert.Equal(t,
+		`sum by(grpc_client_method) (rate(lotus_grpc_client_completed_rpcs{lotus_test_id="test-1"}[60s]))`,
+		sq.grpcRPS(grpcMethodLabel))
+
+	sq = newSummaryQueries(datasource.SummaryQuery{
+		TestID: "test-1",
+		Start:  start,
+		End:    start.Add(10 * time.Hour),
+	})
+	assert.Equal(t, 5*time.Minute, sq.step)
+	assert.Equal(t,
+		`histogram_quantile(0.99, sum by(le) (rate(lotus_http_client_roundtrip_latency_bucket{lotus_test_id="test-1"}[300s])))`,
+		sq.httpLatencyP99())
+}
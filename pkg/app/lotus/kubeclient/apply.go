@@ -0,0 +1,309 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package kubeclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"hash/fnv"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+
+	"go.opencensus.io/stats"
+	"go.opencensus.io/stats/view"
+	"go.opencensus.io/tag"
+	"go.uber.org/zap"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/rest"
+)
+
+var (
+	DriftCorrectionCount = stats.Int64(
+		"kubeclient/drift_corrections",
+		"Number of child resources whose drift from the desired state was corrected",
+		stats.UnitDimensionless,
+	)
+
+	KeyKind, _ = tag.NewKey("kind")
+
+	DriftCorrectionCountView = &view.View{
+		Name:        "kubeclient/drift_corrections",
+		Measure:     DriftCorrectionCount,
+		TagKeys:     []tag.Key{KeyKind},
+		Description: "Count of drift corrections by resource kind",
+		Aggregation: view.Count(),
+	}
+)
+
+// DesiredHashAnnotation is the hash of the desired state last applied to a resource.
+// It tells the updates of the desired state apart from the drifts of the live object.
+const DesiredHashAnnotation = "lotus.lotusload.com/desired-hash"
+
+// apply makes the live object of the given resource match the desired one by
+// using server-side apply. The request is sent only when the live object is missing
+// or some fields managed by the controller differ from the desired state.
+// Only the differences from an unchanged desired state are counted as drift corrections.
+func (c *kubeclient) apply(client rest.Interface, resource, name, namespace string, desired runtime.Object, into runtime.Object) error {
+	body, err := applyBody(desired)
+	if err != nil {
+		return err
+	}
+	hash, err := setDesiredHash(body)
+	if err != nil {
+		return err
+	}
+	live, err := client.Get().
+		Namespace(namespace).
+		Resource(resource).
+		Name(name).
+		Do().
+		Raw()
+	exists := true
+	if errors.IsNotFound(err) {
+		exists = false
+	} else if err != nil {
+		return err
+	}
+
+	var diff []string
+	var previousHash string
+	if exists {
+		diff, err = diffFields(body, live)
+		if err != nil {
+			return err
+		}
+		if len(diff) == 0 {
+			return json.Unmarshal(live, into)
+		}
+		previousHash, err = desiredHash(live)
+		if err != nil {
+			return err
+		}
+	}
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	err = client.Patch(types.ApplyPatchType).
+		Namespace(namespace).
+		Resource(resource).
+		Name(name).
+		Param("fieldManager", FieldManager).
+		Param("force", "true").
+		Body(data).
+		Do().
+		Into(into)
+	if err != nil {
+		return err
+	}
+	if exists && previousHash != hash {
+		c.logger.Debug("updated resource to the new desired state",
+			zap.String("resource", resource),
+			zap.String("name", name),
+			zap.String("namespace", namespace),
+			zap.Strings("fields", diff),
+		)
+		return nil
+	}
+	if exists {
+		c.logger.Debug("corrected drift of resource",
+			zap.String("resource", resource),
+			zap.String("name", name),
+			zap.String("namespace", namespace),
+			zap.Strings("fields", diff),
+		)
+		recordDriftCorrection(resource)
+	}
+	return nil
+}
+
+func recordDriftCorrection(resource string) {
+	ctx, err := tag.New(context.Background(), tag.Upsert(KeyKind, resource))
+	if err != nil {
+		return
+	}
+	stats.Record(ctx, DriftCorrectionCount.M(1))
+}
+
+// applyBody converts the given object into the body of an apply request.
+// The status and all null fields are dropped since the controller
+// does not have any opinion about them.
+func applyBody(obj runtime.Object) (map[string]interface{}, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+	body := make(map[string]interface{})
+	if err := json.Unmarshal(data, &body); err != nil {
+		return nil, err
+	}
+	delete(body, "status")
+	return dropNulls(body).(map[string]interface{}), nil
+}
+
+// setDesiredHash records the hash of the given apply body in its annotations and returns it.
+func setDesiredHash(body map[string]interface{}) (string, error) {
+	data, err := json.Marshal(body)
+	if err != nil {
+		return "", err
+	}
+	hasher := fnv.New32a()
+	hasher.Write(data)
+	hash := strconv.FormatUint(uint64(hasher.Sum32()), 16)
+
+	metadata, ok := body["metadata"].(map[string]interface{})
+	if !ok {
+		metadata = make(map[string]interface{})
+		body["metadata"] = metadata
+	}
+	annotations, ok := metadata["annotations"].(map[string]interface{})
+	if !ok {
+		annotations = make(map[string]interface{})
+		metadata["annotations"] = annotations
+	}
+	annotations[DesiredHashAnnotation] = hash
+	return hash, nil
+}
+
+// desiredHash returns the hash of the desired state last applied to the given live object.
+func desiredHash(live []byte) (string, error) {
+	obj := &metav1.PartialObjectMetadata{}
+	if err := json.Unmarshal(live, obj); err != nil {
+		return "", fmt.Errorf("failed to decode live object: %v", err)
+	}
+	return obj.Annotations[DesiredHashAnnotation], nil
+}
+
+func dropNulls(v interface{}) interface{} {
+	switch value := v.(type) {
+	case map[string]interface{}:
+		for k, child := range value {
+			if child == nil {
+				delete(value, k)
+				continue
+			}
+			value[k] = dropNulls(child)
+		}
+		return value
+	case []interface{}:
+		for i := range value {
+			value[i] = dropNulls(value[i])
+		}
+		return value
+	default:
+		return v
+	}
+}
+
+// diffFields returns the paths of the fields specified in desired
+// whose values are different in the live object.
+// Fields which are only set in the live object, e.g. the ones defaulted by
+// the API server, are ignored.
+func diffFields(desired map[string]interface{}, live []byte) ([]string, error) {
+	current := make(map[string]interface{})
+	if err := json.Unmarshal(live, &current); err != nil {
+		return nil, fmt.Errorf("failed to decode live object: %v", err)
+	}
+	diff := make([]string, 0)
+	collectDiff("", desired, current, &diff)
+	sort.Strings(diff)
+	return diff, nil
+}
+
+func collectDiff(path string, desired, current interface{}, diff *[]string) {
+	switch d := desired.(type) {
+	case map[string]interface{}:
+		c, _ := current.(map[string]interface{})
+		for k, v := range d {
+			if isEmpty(v) && (c == nil || isEmpty(c[k])) {
+				continue
+			}
+			if c == nil {
+				*diff = append(*diff, path+"."+k)
+				continue
+			}
+			collectDiff(path+"."+k, v, c[k], diff)
+		}
+	case []interface{}:
+		c, ok := current.([]interface{})
+		if !ok || len(c) != len(d) {
+			*diff = append(*diff, path)
+			return
+		}
+		for i := range d {
+			collectDiff(fmt.Sprintf("%s[%d]", path, i), d[i], c[i], diff)
+		}
+	default:
+		if !reflect.DeepEqual(desired, current) && !sameQuantity(path, desired, current) {
+			*diff = append(*diff, path)
+		}
+	}
+}
+
+// sameQuantity reports whether the given values of a quantity field are equal,
+// since the API server normalizes quantities, e.g. from 1000m to 1.
+func sameQuantity(path string, desired, current interface{}) bool {
+	if !isQuantityPath(path) {
+		return false
+	}
+	d, ok := desired.(string)
+	if !ok {
+		return false
+	}
+	c, ok := current.(string)
+	if !ok {
+		return false
+	}
+	dq, err := resource.ParseQuantity(d)
+	if err != nil {
+		return false
+	}
+	cq, err := resource.ParseQuantity(c)
+	if err != nil {
+		return false
+	}
+	return dq.Cmp(cq) == 0
+}
+
+func isQuantityPath(path string) bool {
+	return strings.Contains(path, ".resources.limits.") ||
+		strings.Contains(path, ".resources.requests.") ||
+		strings.HasSuffix(path, ".sizeLimit")
+}
+
+func isEmpty(v interface{}) bool {
+	switch value := v.(type) {
+	case nil:
+		return true
+	case map[string]interface{}:
+		return len(value) == 0
+	case []interface{}:
+		return len(value) == 0
+	}
+	return false
+}
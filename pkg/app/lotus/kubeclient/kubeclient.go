@@ -21,6 +21,10 @@
 package kubeclient
 
 import (
+	"encoding/json"
+	"fmt"
+
+	"go.uber.org/zap"
 	appsv1 "k8s.io/api/apps/v1"
 	"k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
@@ -30,43 +34,47 @@ import (
 	batchlisters "k8s.io/client-go/listers/batch/v1"
 )
 
+// FieldManager is the name of the field manager used by
+// the controller for server-side apply requests.
+const FieldManager = "lotus-controller"
+
 type KubeClient interface {
-	EnsureDeployment(name, namespace string, factory func() (*appsv1.Deployment, error)) (*appsv1.Deployment, error)
 	EnsurePod(name, namespace string, factory func() (*corev1.Pod, error)) (*corev1.Pod, error)
-	EnsureService(name, namespace string, factory func() (*corev1.Service, error)) (*corev1.Service, error)
-	EnsureConfigMap(name, namespace string, factory func() (*corev1.ConfigMap, error)) (*corev1.ConfigMap, error)
 	EnsureJob(name, namespace string, factory func() (*v1.Job, error)) (*v1.Job, error)
+	EnsureIndexedJob(name, namespace string, factory func() (*v1.Job, error)) (*v1.Job, error)
 
-	ApplyStatefulSet(name, namespace string, s *appsv1.StatefulSet) error
-	ApplyService(name, namespace string, s *corev1.Service) error
-	ApplyDeployment(name, namespace string, d *appsv1.Deployment) error
-	ApplySecret(name, namespace string, s *corev1.Secret) error
+	ApplyStatefulSet(name, namespace string, s *appsv1.StatefulSet) (*appsv1.StatefulSet, error)
+	ApplyService(name, namespace string, s *corev1.Service) (*corev1.Service, error)
+	ApplyDeployment(name, namespace string, d *appsv1.Deployment) (*appsv1.Deployment, error)
+	ApplySecret(name, namespace string, s *corev1.Secret) (*corev1.Secret, error)
+	ApplyConfigMap(name, namespace string, c *corev1.ConfigMap) (*corev1.ConfigMap, error)
 	GetDeployment(name, namespace string) (*appsv1.Deployment, error)
+	GetService(name, namespace string) (*corev1.Service, error)
+	GetPodTemplate(name, namespace string) (*corev1.PodTemplate, error)
+	GetConfigMap(name, namespace string) (*corev1.ConfigMap, error)
+	GetSecret(name, namespace string) (*corev1.Secret, error)
+	ListResourceQuotas(namespace string) ([]corev1.ResourceQuota, error)
+	ListPods(namespace, selector string) ([]corev1.Pod, error)
 	DeleteDeployment(name, namespace string) error
+	DeleteJob(name, namespace string) error
+	DeletePod(name, namespace string) error
+	DeleteService(name, namespace string) error
+	DeleteConfigMap(name, namespace string) error
+	DeleteSecret(name, namespace string) error
 }
 
-func New(kubeClientSet kubernetes.Interface, jobsLister batchlisters.JobLister) KubeClient {
+func New(kubeClientSet kubernetes.Interface, jobsLister batchlisters.JobLister, logger *zap.Logger) KubeClient {
 	return &kubeclient{
 		kubeClientSet: kubeClientSet,
 		jobsLister:    jobsLister,
+		logger:        logger.Named("kubeclient"),
 	}
 }
 
 type kubeclient struct {
 	kubeClientSet kubernetes.Interface
 	jobsLister    batchlisters.JobLister
-}
-
-func (c *kubeclient) EnsureDeployment(name, namespace string, factory func() (*appsv1.Deployment, error)) (*appsv1.Deployment, error) {
-	deployment, err := c.kubeClientSet.AppsV1().Deployments(namespace).Get(name, metav1.GetOptions{})
-	if !errors.IsNotFound(err) {
-		return deployment, err
-	}
-	deployment, err = factory()
-	if err != nil {
-		return nil, err
-	}
-	return c.kubeClientSet.AppsV1().Deployments(namespace).Create(deployment)
+	logger        *zap.Logger
 }
 
 func (c *kubeclient) EnsurePod(name, namespace string, factory func() (*corev1.Pod, error)) (*corev1.Pod, error) {
@@ -81,98 +89,200 @@ func (c *kubeclient) EnsurePod(name, namespace string, factory func() (*corev1.P
 	return c.kubeClientSet.CoreV1().Pods(namespace).Create(pod)
 }
 
-func (c *kubeclient) EnsureService(name, namespace string, factory func() (*corev1.Service, error)) (*corev1.Service, error) {
-	service, err := c.kubeClientSet.CoreV1().Services(namespace).Get(name, metav1.GetOptions{})
+func (c *kubeclient) EnsureJob(name, namespace string, factory func() (*v1.Job, error)) (*v1.Job, error) {
+	job, err := c.jobsLister.Jobs(namespace).Get(name)
 	if !errors.IsNotFound(err) {
-		return service, err
+		return job, err
 	}
-	service, err = factory()
+	job, err = factory()
 	if err != nil {
 		return nil, err
 	}
-	return c.kubeClientSet.CoreV1().Services(namespace).Create(service)
+	return c.kubeClientSet.BatchV1().Jobs(namespace).Create(job)
 }
 
-func (c *kubeclient) EnsureConfigMap(name, namespace string, factory func() (*corev1.ConfigMap, error)) (*corev1.ConfigMap, error) {
-	configmap, err := c.kubeClientSet.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+// EnsureIndexedJob is the same as EnsureJob except that the Job is created
+// with the Indexed completion mode, so that each pod has its completion index.
+// Since the mode is not a part of the vendored Job API, the Job is created
+// from its unstructured representation.
+func (c *kubeclient) EnsureIndexedJob(name, namespace string, factory func() (*v1.Job, error)) (*v1.Job, error) {
+	job, err := c.jobsLister.Jobs(namespace).Get(name)
 	if !errors.IsNotFound(err) {
-		return configmap, err
+		return job, err
+	}
+	job, err = factory()
+	if err != nil {
+		return nil, err
 	}
-	configmap, err = factory()
+	job = job.DeepCopy()
+	job.TypeMeta = metav1.TypeMeta{APIVersion: "batch/v1", Kind: "Job"}
+	body, err := applyBody(job)
 	if err != nil {
 		return nil, err
 	}
-	return c.kubeClientSet.CoreV1().ConfigMaps(namespace).Create(configmap)
+	spec, ok := body["spec"].(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("job %s has no spec", name)
+	}
+	spec["completionMode"] = "Indexed"
+	data, err := json.Marshal(body)
+	if err != nil {
+		return nil, err
+	}
+	created := &v1.Job{}
+	err = c.kubeClientSet.BatchV1().RESTClient().Post().
+		Namespace(namespace).
+		Resource("jobs").
+		Body(data).
+		Do().
+		Into(created)
+	return created, err
 }
 
-func (c *kubeclient) EnsureJob(name, namespace string, factory func() (*v1.Job, error)) (*v1.Job, error) {
-	job, err := c.jobsLister.Jobs(namespace).Get(name)
-	if !errors.IsNotFound(err) {
-		return job, err
+func (c *kubeclient) ApplyStatefulSet(name, namespace string, s *appsv1.StatefulSet) (*appsv1.StatefulSet, error) {
+	s = s.DeepCopy()
+	s.TypeMeta = metav1.TypeMeta{APIVersion: "apps/v1", Kind: "StatefulSet"}
+	setDefaultPodSpecProtocols(&s.Spec.Template.Spec)
+	result := &appsv1.StatefulSet{}
+	err := c.apply(c.kubeClientSet.AppsV1().RESTClient(), "statefulsets", name, namespace, s, result)
+	return result, err
+}
+
+func (c *kubeclient) ApplyService(name, namespace string, s *corev1.Service) (*corev1.Service, error) {
+	s = s.DeepCopy()
+	s.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Service"}
+	for i := range s.Spec.Ports {
+		if s.Spec.Ports[i].Protocol == "" {
+			s.Spec.Ports[i].Protocol = corev1.ProtocolTCP
+		}
 	}
-	job, err = factory()
+	result := &corev1.Service{}
+	err := c.apply(c.kubeClientSet.CoreV1().RESTClient(), "services", name, namespace, s, result)
+	return result, err
+}
+
+func (c *kubeclient) ApplyDeployment(name, namespace string, d *appsv1.Deployment) (*appsv1.Deployment, error) {
+	d = d.DeepCopy()
+	d.TypeMeta = metav1.TypeMeta{APIVersion: "apps/v1", Kind: "Deployment"}
+	setDefaultPodSpecProtocols(&d.Spec.Template.Spec)
+	result := &appsv1.Deployment{}
+	err := c.apply(c.kubeClientSet.AppsV1().RESTClient(), "deployments", name, namespace, d, result)
+	return result, err
+}
+
+func (c *kubeclient) ApplySecret(name, namespace string, s *corev1.Secret) (*corev1.Secret, error) {
+	s = s.DeepCopy()
+	s.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "Secret"}
+	result := &corev1.Secret{}
+	err := c.apply(c.kubeClientSet.CoreV1().RESTClient(), "secrets", name, namespace, s, result)
+	return result, err
+}
+
+func (c *kubeclient) ApplyConfigMap(name, namespace string, cm *corev1.ConfigMap) (*corev1.ConfigMap, error) {
+	cm = cm.DeepCopy()
+	cm.TypeMeta = metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"}
+	result := &corev1.ConfigMap{}
+	err := c.apply(c.kubeClientSet.CoreV1().RESTClient(), "configmaps", name, namespace, cm, result)
+	return result, err
+}
+
+func (c *kubeclient) GetDeployment(name, namespace string) (*appsv1.Deployment, error) {
+	return c.kubeClientSet.AppsV1().Deployments(namespace).Get(name, metav1.GetOptions{})
+}
+
+func (c *kubeclient) GetService(name, namespace string) (*corev1.Service, error) {
+	return c.kubeClientSet.CoreV1().Services(namespace).Get(name, metav1.GetOptions{})
+}
+
+func (c *kubeclient) GetPodTemplate(name, namespace string) (*corev1.PodTemplate, error) {
+	return c.kubeClientSet.CoreV1().PodTemplates(namespace).Get(name, metav1.GetOptions{})
+}
+
+func (c *kubeclient) GetConfigMap(name, namespace string) (*corev1.ConfigMap, error) {
+	return c.kubeClientSet.CoreV1().ConfigMaps(namespace).Get(name, metav1.GetOptions{})
+}
+
+func (c *kubeclient) GetSecret(name, namespace string) (*corev1.Secret, error) {
+	return c.kubeClientSet.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{})
+}
+
+func (c *kubeclient) ListResourceQuotas(namespace string) ([]corev1.ResourceQuota, error) {
+	list, err := c.kubeClientSet.CoreV1().ResourceQuotas(namespace).List(metav1.ListOptions{})
 	if err != nil {
 		return nil, err
 	}
-	return c.kubeClientSet.BatchV1().Jobs(namespace).Create(job)
+	return list.Items, nil
 }
 
-func (c *kubeclient) ApplyStatefulSet(name, namespace string, s *appsv1.StatefulSet) error {
-	_, err := c.kubeClientSet.AppsV1().StatefulSets(namespace).Get(name, metav1.GetOptions{})
-	if errors.IsNotFound(err) {
-		_, err = c.kubeClientSet.AppsV1().StatefulSets(namespace).Create(s)
-		return err
+func (c *kubeclient) ListPods(namespace, selector string) ([]corev1.Pod, error) {
+	list, err := c.kubeClientSet.CoreV1().Pods(namespace).List(metav1.ListOptions{
+		LabelSelector: selector,
+	})
+	if err != nil {
+		return nil, err
 	}
-	if err == nil {
-		_, err = c.kubeClientSet.AppsV1().StatefulSets(namespace).Update(s)
+	return list.Items, nil
+}
+
+func (c *kubeclient) DeleteDeployment(name, namespace string) error {
+	err := c.kubeClientSet.AppsV1().Deployments(namespace).Delete(name, nil)
+	if err == nil || errors.IsNotFound(err) {
+		return nil
 	}
 	return err
 }
 
-func (c *kubeclient) ApplyService(name, namespace string, s *corev1.Service) error {
-	_, err := c.kubeClientSet.CoreV1().Services(namespace).Get(name, metav1.GetOptions{})
-	if errors.IsNotFound(err) {
-		_, err = c.kubeClientSet.CoreV1().Services(namespace).Create(s)
-		return err
-	}
-	if err == nil {
-		_, err = c.kubeClientSet.CoreV1().Services(namespace).Update(s)
+func (c *kubeclient) DeleteJob(name, namespace string) error {
+	propagation := metav1.DeletePropagationBackground
+	err := c.kubeClientSet.BatchV1().Jobs(namespace).Delete(name, &metav1.DeleteOptions{
+		PropagationPolicy: &propagation,
+	})
+	if err == nil || errors.IsNotFound(err) {
+		return nil
 	}
 	return err
 }
 
-func (c *kubeclient) ApplyDeployment(name, namespace string, d *appsv1.Deployment) error {
-	_, err := c.kubeClientSet.AppsV1().Deployments(namespace).Get(name, metav1.GetOptions{})
-	if errors.IsNotFound(err) {
-		_, err = c.kubeClientSet.AppsV1().Deployments(namespace).Create(d)
-		return err
-	}
-	if err == nil {
-		_, err = c.kubeClientSet.AppsV1().Deployments(namespace).Update(d)
+func (c *kubeclient) DeletePod(name, namespace string) error {
+	err := c.kubeClientSet.CoreV1().Pods(namespace).Delete(name, nil)
+	if err == nil || errors.IsNotFound(err) {
+		return nil
 	}
 	return err
 }
 
-func (c *kubeclient) ApplySecret(name, namespace string, s *corev1.Secret) error {
-	_, err := c.kubeClientSet.CoreV1().Secrets(namespace).Get(name, metav1.GetOptions{})
-	if errors.IsNotFound(err) {
-		_, err = c.kubeClientSet.CoreV1().Secrets(namespace).Create(s)
-		return err
-	}
-	if err == nil {
-		_, err = c.kubeClientSet.CoreV1().Secrets(namespace).Update(s)
+func (c *kubeclient) DeleteService(name, namespace string) error {
+	err := c.kubeClientSet.CoreV1().Services(namespace).Delete(name, nil)
+	if err == nil || errors.IsNotFound(err) {
+		return nil
 	}
 	return err
 }
 
-func (c *kubeclient) GetDeployment(name, namespace string) (*appsv1.Deployment, error) {
-	return c.kubeClientSet.AppsV1().Deployments(namespace).Get(name, metav1.GetOptions{})
+func (c *kubeclient) DeleteConfigMap(name, namespace string) error {
+	err := c.kubeClientSet.CoreV1().ConfigMaps(namespace).Delete(name, nil)
+	if err == nil || errors.IsNotFound(err) {
+		return nil
+	}
+	return err
 }
 
-func (c *kubeclient) DeleteDeployment(name, namespace string) error {
-	err := c.kubeClientSet.AppsV1().Deployments(namespace).Delete(name, nil)
+func (c *kubeclient) DeleteSecret(name, namespace string) error {
+	err := c.kubeClientSet.CoreV1().Secrets(namespace).Delete(name, nil)
 	if err == nil || errors.IsNotFound(err) {
 		return nil
 	}
 	return err
 }
+
+// Server-side apply requires the protocol of ports to be set
+// since it is a part of the key of the port lists.
+func setDefaultPodSpecProtocols(spec *corev1.PodSpec) {
+	for i := range spec.Containers {
+		for j := range spec.Containers[i].Ports {
+			if spec.Containers[i].Ports[j].Protocol == "" {
+				spec.Containers[i].Ports[j].Protocol = corev1.ProtocolTCP
+			}
+		}
+	}
+}
@@ -19,3 +19,94 @@
 // SOFTWARE.
 
 package kubeclient
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestDiffFields(t *testing.T) {
+	desired := &corev1.ConfigMap{
+		TypeMeta: metav1.TypeMeta{APIVersion: "v1", Kind: "ConfigMap"},
+		ObjectMeta: metav1.ObjectMeta{
+			Name:   "lotus-monitor",
+			Labels: map[string]string{"app": "lotus"},
+		},
+		Data: map[string]string{"config.yaml": "foo"},
+	}
+	body, err := applyBody(desired)
+	assert.NoError(t, err)
+	_, ok := body["metadata"].(map[string]interface{})["creationTimestamp"]
+	assert.False(t, ok)
+
+	testcases := []struct {
+		name     string
+		live     string
+		expected []string
+	}{
+		{
+			name:     "same object with defaulted fields",
+			live:     `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"lotus-monitor","uid":"1","labels":{"app":"lotus"}},"data":{"config.yaml":"foo"}}`,
+			expected: []string{},
+		},
+		{
+			name:     "manually edited data",
+			live:     `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"lotus-monitor","labels":{"app":"lotus"}},"data":{"config.yaml":"bar"}}`,
+			expected: []string{".data.config.yaml"},
+		},
+		{
+			name:     "removed labels",
+			live:     `{"apiVersion":"v1","kind":"ConfigMap","metadata":{"name":"lotus-monitor"},"data":{"config.yaml":"foo"}}`,
+			expected: []string{".metadata.labels.app"},
+		},
+	}
+	for _, tc := range testcases {
+		t.Run(tc.name, func(t *testing.T) {
+			diff, err := diffFields(body, []byte(tc.live))
+			assert.NoError(t, err)
+			assert.Equal(t, tc.expected, diff)
+		})
+	}
+}
+
+func TestDiffFieldsQuantity(t *testing.T) {
+	desired := map[string]interface{}{
+		"spec": map[string]interface{}{
+			"resources": map[string]interface{}{
+				"limits": map[string]interface{}{"cpu": "1000m", "memory": "512Mi"},
+			},
+		},
+	}
+	diff, err := diffFields(desired, []byte(`{"spec":{"resources":{"limits":{"cpu":"1","memory":"512Mi"}}}}`))
+	assert.NoError(t, err)
+	assert.Empty(t, diff)
+
+	diff, err = diffFields(desired, []byte(`{"spec":{"resources":{"limits":{"cpu":"2","memory":"512Mi"}}}}`))
+	assert.NoError(t, err)
+	assert.Equal(t, []string{".spec.resources.limits.cpu"}, diff)
+}
+
+func TestDesiredHash(t *testing.T) {
+	body := map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "lotus-monitor"},
+		"data":     map[string]interface{}{"config.yaml": "foo"},
+	}
+	hash, err := setDesiredHash(body)
+	assert.NoError(t, err)
+	live := `{"metadata":{"name":"lotus-monitor","annotations":{"lotus.lotusload.com/desired-hash":"` + hash + `"}},"data":{"config.yaml":"bar"}}`
+	previous, err := desiredHash([]byte(live))
+	assert.NoError(t, err)
+	// The live object has drifted from the unchanged desired state.
+	assert.Equal(t, hash, previous)
+
+	updated := map[string]interface{}{
+		"metadata": map[string]interface{}{"name": "lotus-monitor"},
+		"data":     map[string]interface{}{"config.yaml": "baz"},
+	}
+	updatedHash, err := setDesiredHash(updated)
+	assert.NoError(t, err)
+	assert.NotEqual(t, hash, updatedHash)
+}
@@ -27,6 +27,8 @@ import (
 
 const (
 	LotusKind = "Lotus"
+	// TestIDLabel is added to all metrics scraped from the workers of a test.
+	TestIDLabel = "lotus_test_id"
 )
 
 var (
@@ -20,6 +20,11 @@
 
 package model
 
+import (
+	"math"
+	"strconv"
+)
+
 const (
 	NoDataValue float64 = -1
 )
@@ -29,14 +34,38 @@ type MetricsSummary struct {
 	GRPCFailurePercentage float64
 	GRPCAll               ValueByLabel
 	GRPCByMethod          map[string]ValueByLabel
+	// The number of completed RPCs by grpc_client_status, overall and per method.
+	GRPCStatusCounts         ValueByLabel
+	GRPCStatusCountsByMethod map[string]ValueByLabel
 
 	HTTPRequestTotal      float64
 	HTTPFailurePercentage float64
 	HTTPAll               ValueByLabel
 	HTTPByPath            map[string]ValueByLabel
+	// The number of completed requests by http_client_status, overall and per path.
+	HTTPStatusCounts       ValueByLabel
+	HTTPStatusCountsByPath map[string]ValueByLabel
 
 	VirtualUserStartedTotal float64
 	VirtualUserFailedTotal  float64
+
+	// LatencyQuantiles are the quantiles whose latencies are included
+	// in the values by label, see LatencyPercentileKey.
+	LatencyQuantiles []float64
+
+	// CustomMetrics are the user-defined metrics in the order of the config.
+	CustomMetrics []CustomMetric `json:",omitempty"`
+}
+
+// CustomMetric is the result of a user-defined summary query.
+// A grouped metric has a value for each combination of the values of its GroupBy labels,
+// keyed by the values joined with "/". Otherwise the single value is in Value.
+type CustomMetric struct {
+	Name    string
+	GroupBy []string `json:",omitempty"`
+	Value   float64
+	Groups  ValueByLabel `json:",omitempty"`
+	Error   string       `json:",omitempty"`
 }
 
 type ValueByLabel map[string]float64
@@ -53,4 +82,18 @@ const (
 	HTTPLatencyAvgKey        = "LatencyAvg"
 	HTTPSentBytesAvgKey      = "SentBytesAvg"
 	HTTPReceivedBytesAvgKey  = "ReceivedBytesAvg"
+
+	// RPSAvgKey and RPSPeakKey are the average and peak of the requests per second
+	// sampled at every step of the test window.
+	RPSAvgKey  = "RPSAvg"
+	RPSPeakKey = "RPSPeak"
+
+	// LatencyMaxKey is the upper bound of the highest non-empty latency bucket.
+	LatencyMaxKey = "LatencyMax"
 )
+
+// LatencyPercentileKey returns the key of the latency at the given quantile, e.g. LatencyP99 for 0.99.
+func LatencyPercentileKey(quantile float64) string {
+	percentile := math.Round(quantile*100*1000) / 1000
+	return "LatencyP" + strconv.FormatFloat(percentile, 'f', -1, 64)
+}
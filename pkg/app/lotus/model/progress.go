@@ -0,0 +1,51 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+package model
+
+import (
+	"time"
+)
+
+// Progress is a snapshot of a running test served by the monitor.
+type Progress struct {
+	TestID           string
+	StartedTimestamp time.Time
+	UpdatedTimestamp time.Time
+	Elapsed          time.Duration
+	Remaining        time.Duration
+	// Requests per second of HTTP and gRPC over the last minute.
+	RPS float64
+	// Percentage of the failed HTTP requests and gRPC calls over the last minute.
+	ErrorPercentage float64
+	// The checks which are currently firing.
+	FiringChecks   []string
+	DataSources    []DataSourceProgress
+	MetricsSummary *MetricsSummary
+}
+
+// DataSourceProgress is the result of the last check round against a datasource.
+type DataSourceProgress struct {
+	Name                string
+	LastCheckTimestamp  time.Time
+	Actives             []string
+	Pendings            []string
+	ConsecutiveFailures int
+	Error               string `json:",omitempty"`
+}
@@ -24,6 +24,8 @@ import (
 	"bytes"
 	"fmt"
 	"math"
+	"sort"
+	"strings"
 	"text/template"
 	"time"
 )
@@ -46,10 +48,14 @@ type valueByLabelList struct {
 
 func renderTemplate(result *Result, tpl string) ([]byte, error) {
 	funcMap := template.FuncMap{
-		"formatValue":        formatValue,
-		"formatTime":         formatTime,
-		"formatGRPCByMethod": formatGRPCByMethod,
-		"formatHTTPByPath":   formatHTTPByPath,
+		"formatValue":         formatValue,
+		"formatTime":          formatTime,
+		"formatGRPCByMethod":  formatGRPCByMethod,
+		"formatHTTPByPath":    formatHTTPByPath,
+		"formatStatusCounts":  formatStatusCounts,
+		"formatCustomMetrics": formatCustomMetrics,
+		"formatFiredChecks":   formatFiredChecks,
+		"formatAssertions":    formatAssertions,
 	}
 	template, err := template.New("result").Funcs(funcMap).Parse(tpl)
 	if err != nil {
@@ -63,108 +69,208 @@ func renderTemplate(result *Result, tpl string) ([]byte, error) {
 	return buffer.Bytes(), nil
 }
 
-func formatGRPCByMethod(data map[string]ValueByLabel, all ValueByLabel) string {
-	keys := []struct {
-		Desc string
-		Key  string
-	}{
-		{Desc: "RPCs", Key: GRPCRPCsKey},
-		{Desc: `Failure%`, Key: GRPCFailurePercentageKey},
-		{Desc: "Latency(ms)", Key: GRPCLatencyAvgKey},
-		{Desc: "SentBytes", Key: GRPCSentBytesAvgKey},
-		{Desc: "RecvBytes", Key: GRPCReceivedBytesAvgKey},
-	}
-	methodMaxLength := 5
-	for method, _ := range data {
-		if len(method) > methodMaxLength {
-			methodMaxLength = len(method)
-		}
+type valueColumn struct {
+	Desc  string
+	Key   string
+	Width int
+}
+
+// latencyColumns returns the columns of the given latency quantiles followed by the max latency.
+func latencyColumns(quantiles []float64) []valueColumn {
+	columns := make([]valueColumn, 0, len(quantiles)+1)
+	for _, q := range quantiles {
+		key := LatencyPercentileKey(q)
+		columns = append(columns, valueColumn{
+			Desc:  strings.TrimPrefix(key, "Latency") + "(ms)",
+			Key:   key,
+			Width: 10,
+		})
 	}
-	var b bytes.Buffer
-	format := func(key string, values ValueByLabel) string {
-		value, ok := values[key]
-		if !ok {
-			value = NoDataValue
+	return append(columns, valueColumn{Desc: "Max(ms)", Key: LatencyMaxKey, Width: 10})
+}
+
+func formatGRPCByMethod(data map[string]ValueByLabel, all ValueByLabel, quantiles []float64) string {
+	columns := []valueColumn{
+		{Desc: "RPCs", Key: GRPCRPCsKey, Width: 8},
+		{Desc: "RPS(avg)", Key: RPSAvgKey, Width: 8},
+		{Desc: "RPS(peak)", Key: RPSPeakKey, Width: 9},
+		{Desc: `Failure%`, Key: GRPCFailurePercentageKey, Width: 8},
+		{Desc: "Latency(ms)", Key: GRPCLatencyAvgKey, Width: 12},
+	}
+	columns = append(columns, latencyColumns(quantiles)...)
+	columns = append(columns,
+		valueColumn{Desc: "SentBytes", Key: GRPCSentBytesAvgKey, Width: 8},
+		valueColumn{Desc: "RecvBytes", Key: GRPCReceivedBytesAvgKey, Width: 8},
+	)
+	return formatValueTable(columns, data, all)
+}
+
+func formatHTTPByPath(data map[string]ValueByLabel, all ValueByLabel, quantiles []float64) string {
+	columns := []valueColumn{
+		{Desc: "Requests", Key: HTTPRequestsKey, Width: 8},
+		{Desc: "RPS(avg)", Key: RPSAvgKey, Width: 8},
+		{Desc: "RPS(peak)", Key: RPSPeakKey, Width: 9},
+		{Desc: `Failure%`, Key: HTTPFailurePercentageKey, Width: 8},
+		{Desc: "Latency(ms)", Key: HTTPLatencyAvgKey, Width: 12},
+	}
+	columns = append(columns, latencyColumns(quantiles)...)
+	columns = append(columns,
+		valueColumn{Desc: "SentBytes", Key: HTTPSentBytesAvgKey, Width: 8},
+		valueColumn{Desc: "RecvBytes", Key: HTTPReceivedBytesAvgKey, Width: 8},
+	)
+	return formatValueTable(columns, data, all)
+}
+
+// formatStatusCounts renders the counts of each status code as a column.
+func formatStatusCounts(data map[string]ValueByLabel, all ValueByLabel) string {
+	statuses := make([]string, 0, len(all))
+	for status := range all {
+		statuses = append(statuses, status)
+	}
+	sort.Strings(statuses)
+	columns := make([]valueColumn, 0, len(statuses))
+	for _, status := range statuses {
+		width := len(status)
+		if width < 8 {
+			width = 8
 		}
-		return formatValue(value)
+		columns = append(columns, valueColumn{Desc: status, Key: status, Width: width})
 	}
-	titleFormat := fmt.Sprintf("    %%-%ds  %%-8s  %%-8s  %%-12s  %%-8s  %%-8s\n\n", methodMaxLength)
-	detailFormat := fmt.Sprintf("  - %%-%ds  %%-8s  %%-8s  %%-12s  %%-8s  %%-8s\n", methodMaxLength)
+	return formatValueTable(columns, data, all)
+}
 
-	b.WriteString(fmt.Sprintf(titleFormat, "", keys[0].Desc, keys[1].Desc, keys[2].Desc, keys[3].Desc, keys[4].Desc))
+// formatValueTable renders a row for each label followed by the row of all labels.
+func formatValueTable(columns []valueColumn, data map[string]ValueByLabel, all ValueByLabel) string {
+	nameMaxLength := 5
+	for name := range data {
+		if len(name) > nameMaxLength {
+			nameMaxLength = len(name)
+		}
+	}
 	rows := make([]valueByLabelList, 0, len(data)+1)
-	for method, values := range data {
+	for name, values := range data {
 		rows = append(rows, valueByLabelList{
-			name:   method,
+			name:   name,
 			values: values,
 		})
 	}
+	sort.Slice(rows, func(i, j int) bool {
+		return rows[i].name < rows[j].name
+	})
 	rows = append(rows, valueByLabelList{
 		name:   "all",
 		values: all,
 	})
+
+	var b bytes.Buffer
+	b.WriteString(fmt.Sprintf("    %-*s", nameMaxLength, ""))
+	for _, c := range columns {
+		b.WriteString(fmt.Sprintf("  %-*s", c.Width, c.Desc))
+	}
+	b.WriteString("\n\n")
 	for _, row := range rows {
-		b.WriteString(fmt.Sprintf(detailFormat,
-			row.name,
-			format(keys[0].Key, row.values),
-			format(keys[1].Key, row.values),
-			format(keys[2].Key, row.values),
-			format(keys[3].Key, row.values),
-			format(keys[4].Key, row.values),
-		))
+		b.WriteString(fmt.Sprintf("  - %-*s", nameMaxLength, row.name))
+		for _, c := range columns {
+			value, ok := row.values[c.Key]
+			if !ok {
+				value = NoDataValue
+			}
+			b.WriteString(fmt.Sprintf("  %-*s", c.Width, formatValue(value)))
+		}
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+// formatCustomMetrics renders a row for each scalar metric
+// and a nested row for each group of the grouped metrics.
+func formatCustomMetrics(metrics []CustomMetric) string {
+	nameMaxLength := 5
+	for _, m := range metrics {
+		if len(m.Name) > nameMaxLength {
+			nameMaxLength = len(m.Name)
+		}
+		for key := range m.Groups {
+			if len(key)+2 > nameMaxLength {
+				nameMaxLength = len(key) + 2
+			}
+		}
+	}
+	var b bytes.Buffer
+	for _, m := range metrics {
+		switch {
+		case m.Error != "":
+			b.WriteString(fmt.Sprintf("  - %-*s  error: %s\n", nameMaxLength, m.Name, m.Error))
+		case len(m.GroupBy) == 0:
+			b.WriteString(fmt.Sprintf("  - %-*s  %s\n", nameMaxLength, m.Name, formatValue(m.Value)))
+		default:
+			b.WriteString(fmt.Sprintf("  - %s (by %s)\n", m.Name, strings.Join(m.GroupBy, "/")))
+			keys := make([]string, 0, len(m.Groups))
+			for key := range m.Groups {
+				keys = append(keys, key)
+			}
+			sort.Strings(keys)
+			for _, key := range keys {
+				b.WriteString(fmt.Sprintf("    - %-*s  %s\n", nameMaxLength-2, key, formatValue(m.Groups[key])))
+			}
+		}
 	}
 	return b.String()
 }
 
-func formatHTTPByPath(data map[string]ValueByLabel, all ValueByLabel) string {
-	keys := []struct {
-		Desc string
-		Key  string
+func formatFiredChecks(checks []FiredCheck) string {
+	groups := []struct {
+		Desc     string
+		Severity CheckSeverity
 	}{
-		{Desc: "Requests", Key: HTTPRequestsKey},
-		{Desc: `Failure%`, Key: HTTPFailurePercentageKey},
-		{Desc: "Latency(ms)", Key: HTTPLatencyAvgKey},
-		{Desc: "SentBytes", Key: HTTPSentBytesAvgKey},
-		{Desc: "RecvBytes", Key: HTTPReceivedBytesAvgKey},
-	}
-	pathMaxLength := 5
-	for path, _ := range data {
-		if len(path) > pathMaxLength {
-			pathMaxLength = len(path)
+		{Desc: "Abort", Severity: CheckSeverityAbort},
+		{Desc: "Fail", Severity: CheckSeverityFail},
+		{Desc: "Warn", Severity: CheckSeverityWarn},
+	}
+	nameMaxLength := 5
+	for _, c := range checks {
+		if len(c.Name) > nameMaxLength {
+			nameMaxLength = len(c.Name)
 		}
 	}
+	detailFormat := fmt.Sprintf("    - %%-%ds  fired at %%s for %%s, peak %%s\n", nameMaxLength)
 	var b bytes.Buffer
-	format := func(key string, values ValueByLabel) string {
-		value, ok := values[key]
-		if !ok {
-			value = NoDataValue
+	for _, g := range groups {
+		var lines bytes.Buffer
+		for _, c := range checks {
+			if c.Severity != g.Severity {
+				continue
+			}
+			lines.WriteString(fmt.Sprintf(detailFormat, c.Name, formatTime(c.FiredTimestamp), c.Duration, formatValue(c.PeakValue)))
 		}
-		return formatValue(value)
+		if lines.Len() == 0 {
+			continue
+		}
+		b.WriteString(fmt.Sprintf("  %s:\n", g.Desc))
+		b.Write(lines.Bytes())
 	}
-	titleFormat := fmt.Sprintf("    %%-%ds  %%-8s  %%-8s  %%-12s  %%-8s  %%-8s\n\n", pathMaxLength)
-	detailFormat := fmt.Sprintf("  - %%-%ds  %%-8s  %%-8s  %%-12s  %%-8s  %%-8s\n", pathMaxLength)
+	return b.String()
+}
 
-	b.WriteString(fmt.Sprintf(titleFormat, "", keys[0].Desc, keys[1].Desc, keys[2].Desc, keys[3].Desc, keys[4].Desc))
-	rows := make([]valueByLabelList, 0, len(data)+1)
-	for path, values := range data {
-		rows = append(rows, valueByLabelList{
-			name:   path,
-			values: values,
-		})
+func formatAssertions(assertions []AssertionResult) string {
+	nameMaxLength := 5
+	for _, a := range assertions {
+		if len(a.Name) > nameMaxLength {
+			nameMaxLength = len(a.Name)
+		}
 	}
-	rows = append(rows, valueByLabelList{
-		name:   "all",
-		values: all,
-	})
-	for _, row := range rows {
-		b.WriteString(fmt.Sprintf(detailFormat,
-			row.name,
-			format(keys[0].Key, row.values),
-			format(keys[1].Key, row.values),
-			format(keys[2].Key, row.values),
-			format(keys[3].Key, row.values),
-			format(keys[4].Key, row.values),
-		))
+	detailFormat := fmt.Sprintf("  - %%-%ds  %%-6s  %%s\n", nameMaxLength)
+	var b bytes.Buffer
+	for _, a := range assertions {
+		status := "PASSED"
+		if !a.Passed {
+			status = "FAILED"
+		}
+		detail := fmt.Sprintf("%s %s %s", formatValue(a.Value), a.Operator, formatValue(a.Threshold))
+		if a.Error != "" {
+			detail = fmt.Sprintf("error: %s", a.Error)
+		}
+		b.WriteString(fmt.Sprintf(detailFormat, a.Name, status, detail))
 	}
 	return b.String()
 }
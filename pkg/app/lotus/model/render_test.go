@@ -37,6 +37,9 @@ func TestRender(t *testing.T) {
 			GRPCRPCsKey:              25000000,
 			GRPCFailurePercentageKey: 1.207,
 			GRPCLatencyAvgKey:        135,
+			"LatencyP50":             98,
+			"LatencyP99":             412,
+			LatencyMaxKey:            1000,
 			GRPCSentBytesAvgKey:      12,
 			GRPCReceivedBytesAvgKey:  245,
 		},
@@ -56,6 +59,22 @@ func TestRender(t *testing.T) {
 				GRPCReceivedBytesAvgKey:  256,
 			},
 		},
+		GRPCStatusCounts: map[string]float64{
+			"OK":                24700000,
+			"DEADLINE_EXCEEDED": 250000,
+			"UNAVAILABLE":       50000,
+		},
+		GRPCStatusCountsByMethod: map[string]ValueByLabel{
+			"helloworld.Hello": map[string]float64{
+				"OK":                12450000,
+				"DEADLINE_EXCEEDED": 50000,
+			},
+			"helloworld.Profile": map[string]float64{
+				"OK":                12250000,
+				"DEADLINE_EXCEEDED": 200000,
+				"UNAVAILABLE":       50000,
+			},
+		},
 		HTTPRequestTotal:      10,
 		HTTPFailurePercentage: 1.05890,
 		HTTPAll: map[string]float64{
@@ -64,6 +83,11 @@ func TestRender(t *testing.T) {
 		},
 		VirtualUserStartedTotal: 1000000,
 		VirtualUserFailedTotal:  0,
+		LatencyQuantiles:        []float64{0.5, 0.99},
+		CustomMetrics: []CustomMetric{
+			CustomMetric{Name: "OrdersPlaced", Value: 120345},
+			CustomMetric{Name: "CacheHitRatio", GroupBy: []string{"region"}, Groups: ValueByLabel{"eu": 0.88, "us": 0.93}},
+		},
 	}
 	testcases := []struct {
 		Result *Result
@@ -77,6 +101,39 @@ func TestRender(t *testing.T) {
 				FinishedTimestamp: time.Now(),
 			},
 		},
+		{
+			Result: &Result{
+				TestID:         "test-scenario-12345",
+				Status:         TestFailed,
+				FailureReason:  "1 checks are failed",
+				FailedChecks:   []string{"HighLatency"},
+				MetricsSummary: metricsSummary,
+				FiredChecks: []FiredCheck{
+					FiredCheck{
+						Name:           "HighLatency",
+						Severity:       CheckSeverityFail,
+						FiredTimestamp: time.Now().Add(-5 * time.Minute),
+						Duration:       2 * time.Minute,
+					},
+					FiredCheck{
+						Name:           "HighMemory",
+						Severity:       CheckSeverityWarn,
+						FiredTimestamp: time.Now().Add(-3 * time.Minute),
+						Duration:       30 * time.Second,
+					},
+				},
+				Assertions: []AssertionResult{
+					AssertionResult{
+						Name:      "P99Latency",
+						Operator:  "<",
+						Threshold: 300,
+						Value:     412,
+					},
+				},
+				StartedTimestamp:  time.Now().Add(-10 * time.Minute),
+				FinishedTimestamp: time.Now(),
+			},
+		},
 	}
 	for _, tc := range testcases {
 		tc.Result.SetGrafanaDashboardURLs("http://localhost:3000")
@@ -86,6 +143,63 @@ func TestRender(t *testing.T) {
 	}
 }
 
+func TestLatencyPercentileKey(t *testing.T) {
+	assert.Equal(t, "LatencyP50", LatencyPercentileKey(0.5))
+	assert.Equal(t, "LatencyP95", LatencyPercentileKey(0.95))
+	assert.Equal(t, "LatencyP99.9", LatencyPercentileKey(0.999))
+}
+
+func TestFormatHTTPByPath(t *testing.T) {
+	out := formatHTTPByPath(
+		map[string]ValueByLabel{
+			"GET/example.com/users": ValueByLabel{HTTPRequestsKey: 10, RPSAvgKey: 2.5, RPSPeakKey: 4, "LatencyP99": 250},
+		},
+		ValueByLabel{HTTPRequestsKey: 10, LatencyMaxKey: 1000},
+		[]float64{0.99},
+	)
+	expected := "                           Requests  RPS(avg)  RPS(peak)  Failure%  Latency(ms)   P99(ms)     Max(ms)     SentBytes  RecvBytes\n\n" +
+		"  - GET/example.com/users  10        2.5       4          --        --            250         --          --        --      \n" +
+		"  - all                    10        --        --         --        --            --          1k          --        --      \n"
+	assert.Equal(t, expected, out)
+}
+
+func TestFormatCustomMetrics(t *testing.T) {
+	out := formatCustomMetrics([]CustomMetric{
+		CustomMetric{Name: "OrdersPlaced", Value: 120345},
+		CustomMetric{Name: "CacheHitRatio", GroupBy: []string{"region", "tier"}, Groups: ValueByLabel{"us/gold": 0.93, "eu/gold": 0.88}},
+		CustomMetric{Name: "Refunds", Value: NoDataValue, Error: "bad_data"},
+	})
+	expected := "  - OrdersPlaced   120.3k\n" +
+		"  - CacheHitRatio (by region/tier)\n" +
+		"    - eu/gold      880m\n" +
+		"    - us/gold      930m\n" +
+		"  - Refunds        error: bad_data\n"
+	assert.Equal(t, expected, out)
+}
+
+func TestFormatFiredChecks(t *testing.T) {
+	fired := time.Date(2019, 1, 1, 12, 0, 0, 0, time.UTC)
+	out := formatFiredChecks([]FiredCheck{
+		FiredCheck{Name: "HighMemory", Severity: CheckSeverityWarn, FiredTimestamp: fired, Duration: time.Minute, PeakValue: 2048},
+		FiredCheck{Name: "NoWorker", Severity: CheckSeverityAbort, FiredTimestamp: fired, Duration: 0, PeakValue: NoDataValue},
+	})
+	expected := "  Abort:\n" +
+		"    - NoWorker    fired at 12:00:00 2019-01-01 for 0s, peak --\n" +
+		"  Warn:\n" +
+		"    - HighMemory  fired at 12:00:00 2019-01-01 for 1m0s, peak 2.048k\n"
+	assert.Equal(t, expected, out)
+}
+
+func TestFormatAssertions(t *testing.T) {
+	out := formatAssertions([]AssertionResult{
+		AssertionResult{Name: "P99Latency", Operator: "<", Threshold: 300, Value: 245.5, Passed: true},
+		AssertionResult{Name: "ErrorRate", Operator: "<", Threshold: 0.001, Value: NoDataValue, Error: "no data"},
+	})
+	expected := "  - P99Latency  PASSED  245.5 < 300\n" +
+		"  - ErrorRate   FAILED  error: no data\n"
+	assert.Equal(t, expected, out)
+}
+
 func TestFormatValue(t *testing.T) {
 	testcases := []struct {
 		Value    float64
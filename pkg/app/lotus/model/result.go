@@ -33,16 +33,83 @@ const (
 	TestSucceeded TestStatus = "Succeeded"
 	TestFailed               = "Failed"
 	TestCancelled            = "Cancelled"
+	// TestInProgress is the status of the interim results sent while the test is running.
+	TestInProgress = "InProgress"
 )
 
+type CheckSeverity string
+
+const (
+	CheckSeverityAbort CheckSeverity = "abort"
+	CheckSeverityFail                = "fail"
+	CheckSeverityWarn                = "warn"
+)
+
+type CheckState string
+
+const (
+	CheckInactive CheckState = "inactive"
+	CheckPending             = "pending"
+	CheckFiring              = "firing"
+)
+
+// CheckEvaluation is the outcome of a single evaluation of a check.
+type CheckEvaluation struct {
+	Timestamp time.Time
+	State     CheckState
+	// The worst value returned by the expression, or NoDataValue if it returned nothing.
+	// It is the lowest value for the checks which are active below a threshold, otherwise the highest one.
+	Value float64
+}
+
+// FiredCheck is a period during which a check was firing.
+type FiredCheck struct {
+	Name     string
+	Severity CheckSeverity
+	// When the check started firing.
+	FiredTimestamp time.Time
+	// When the check stopped firing, or the end of the test if it was still firing.
+	ResolvedTimestamp time.Time
+	Duration          time.Duration
+	// The worst value observed during the period, or NoDataValue.
+	PeakValue float64
+	// The evaluations from the first pending one until the check stopped firing,
+	// which tell when the system started degrading.
+	Evaluations []CheckEvaluation `json:",omitempty"`
+}
+
+// AssertionResult is the outcome of an assertion evaluated over the whole test run.
+type AssertionResult struct {
+	Name      string
+	Expr      string
+	Operator  string
+	Threshold float64
+	// The observed value, or NoDataValue if it could not be evaluated.
+	Value  float64
+	Passed bool
+	Error  string `json:",omitempty"`
+}
+
 type Result struct {
-	TestID                   string
-	Status                   TestStatus
-	MetricsSummary           *MetricsSummary
-	FailureReason            string
-	FailedChecks             []string
-	StartedTimestamp         time.Time
-	FinishedTimestamp        time.Time
+	// TestID identifies the run in the metrics, while LotusName is the name of the tested Lotus.
+	TestID    string
+	LotusName string `json:",omitempty"`
+	// TestedImage is the image of the target service whose rollout triggered the test.
+	TestedImage       string `json:",omitempty"`
+	Status            TestStatus
+	MetricsSummary    *MetricsSummary
+	FailureReason     string
+	FailedChecks      []string
+	FiredChecks       []FiredCheck
+	Assertions        []AssertionResult
+	TimeSeries        *TimeSeries `json:",omitempty"`
+	StartedTimestamp  time.Time
+	FinishedTimestamp time.Time
+	// How long the test actually ran, which is shorter than runTime
+	// when the test was stopped early. For interim results it is the elapsed time.
+	RunDuration time.Duration
+	// Why the Running phase ended.
+	StopReason               string
 	GrafanaGRPCDashboardsURL string
 	GrafanaHTTPDashboardsURL string
 }
@@ -60,6 +127,14 @@ func (r *Result) SetGrafanaDashboardURLs(base string) {
 	r.GrafanaHTTPDashboardsURL = fmt.Sprintf("%s/dashboard/db/http?from=%d&to=%d&var-testId=%s", base, from, to, r.TestID)
 }
 
+// Name returns the name of the tested Lotus, or the test ID if it is unknown.
+func (r *Result) Name() string {
+	if r.LotusName != "" {
+		return r.LotusName
+	}
+	return r.TestID
+}
+
 func (r *Result) Render(format RenderFormat) ([]byte, error) {
 	switch format {
 	case RenderFormatMarkdown:
@@ -23,6 +23,12 @@ package model
 const (
 	textTemplate = `
 TestID:        {{ .TestID }}
+{{- if .LotusName }}
+Lotus:         {{ .LotusName }}
+{{- end }}
+{{- if .TestedImage }}
+TestedImage:   {{ .TestedImage }}
+{{- end }}
 TestStatus:    {{ .Status }}
 {{- if eq .Status "Failed" }}
     Reason: {{ .FailureReason }}
@@ -32,6 +38,20 @@ TestStatus:    {{ .Status }}
 {{- end }}
 Start:         {{ formatTime .StartedTimestamp }}
 End:           {{ formatTime .FinishedTimestamp }}
+Duration:      {{ .RunDuration }}
+{{- if .StopReason }}
+StopReason:    {{ .StopReason }}
+{{- end }}
+{{- if gt (len .FiredChecks) 0 }}
+
+FiredChecks:
+{{ formatFiredChecks .FiredChecks }}
+{{- end }}
+{{- if gt (len .Assertions) 0 }}
+
+Assertions:
+{{ formatAssertions .Assertions }}
+{{- end }}
 
 MetricsSummary:
 
@@ -46,7 +66,11 @@ MetricsSummary:
   - FailurePercentage:   {{ formatValue .MetricsSummary.GRPCFailurePercentage }}
 
 GroupByMethod:
-{{ formatGRPCByMethod .MetricsSummary.GRPCByMethod .MetricsSummary.GRPCAll }}
+{{ formatGRPCByMethod .MetricsSummary.GRPCByMethod .MetricsSummary.GRPCAll .MetricsSummary.LatencyQuantiles }}
+{{- if .MetricsSummary.GRPCStatusCounts }}
+GroupByStatus:
+{{ formatStatusCounts .MetricsSummary.GRPCStatusCountsByMethod .MetricsSummary.GRPCStatusCounts }}
+{{- end }}
 Grafana: {{ .GrafanaGRPCDashboardsURL }}
 
 3. HTTP
@@ -54,8 +78,17 @@ Grafana: {{ .GrafanaGRPCDashboardsURL }}
   - FailurePercentage:   {{ formatValue .MetricsSummary.HTTPFailurePercentage }}
 
 GroupByPath:
-{{ formatHTTPByPath .MetricsSummary.HTTPByPath .MetricsSummary.HTTPAll }}
+{{ formatHTTPByPath .MetricsSummary.HTTPByPath .MetricsSummary.HTTPAll .MetricsSummary.LatencyQuantiles }}
+{{- if .MetricsSummary.HTTPStatusCounts }}
+GroupByStatus:
+{{ formatStatusCounts .MetricsSummary.HTTPStatusCountsByPath .MetricsSummary.HTTPStatusCounts }}
+{{- end }}
 Grafana: {{ .GrafanaHTTPDashboardsURL }}
+{{- if .MetricsSummary.CustomMetrics }}
+
+4. Custom
+{{ formatCustomMetrics .MetricsSummary.CustomMetrics }}
+{{- end }}
 {{- else }}
 
   No data
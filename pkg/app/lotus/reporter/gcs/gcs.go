@@ -88,7 +88,8 @@ func (g *gcs) Report(ctx context.Context, result *model.Result) (lastErr error)
 			lastErr = err
 			continue
 		}
-		filename := fmt.Sprintf("%s/%s.%s", result.TestID, result.TestID, c.extension)
+		// The results are stored by the name of the Lotus, so that they can be looked up by the name.
+		filename := fmt.Sprintf("%s/%s.%s", result.Name(), result.Name(), c.extension)
 		g.logger.Info("writing test result to gcs storage",
 			zap.String("testID", result.TestID),
 			zap.String("filename", filename),
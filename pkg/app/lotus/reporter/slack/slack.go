@@ -83,15 +83,18 @@ func (s *slack) Report(ctx context.Context, result *model.Result) error {
 		return err
 	}
 	att := &Attachment{
-		Title: fmt.Sprintf("%s %s", result.TestID, result.Status),
+		Title: fmt.Sprintf("%s %s", result.Name(), result.Status),
 		Text:  fmt.Sprintf("```%s```", string(data)),
 		Color: "danger",
 		MarkdownIn: []string{
 			"text",
 		},
 	}
-	if result.Status == model.TestSucceeded {
+	switch result.Status {
+	case model.TestSucceeded:
 		att.Color = "good"
+	case model.TestInProgress:
+		att.Color = "warning"
 	}
 	msg := &Message{
 		Attachments: []*Attachment{att},
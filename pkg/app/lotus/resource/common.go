@@ -0,0 +1,152 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package resource
+
+import (
+	corev1 "k8s.io/api/core/v1"
+
+	lotusv1beta1 "github.com/lotusload/lotus/pkg/app/lotus/apis/lotus/v1beta1"
+)
+
+const (
+	testIDEnv    = "LOTUS_TEST_ID"
+	nameEnv      = "LOTUS_NAME"
+	stageEnv     = "LOTUS_STAGE"
+	namespaceEnv = "LOTUS_NAMESPACE"
+
+	workerStage = "worker"
+)
+
+// testID identifies a run of the Lotus in the metrics and the result.
+// Unlike the name, the UID tells apart the runs of Lotuses reusing the same name.
+func testID(lotus *lotusv1beta1.Lotus) string {
+	return string(lotus.UID)
+}
+
+// WithResolvedTemplates returns a copy of the given Lotus whose stages have
+// the containers and volumes of their resolved templates before the ones specified inline.
+// The given Lotus is returned as is if it has no resolved template.
+func WithResolvedTemplates(lotus *lotusv1beta1.Lotus) *lotusv1beta1.Lotus {
+	resolved := lotus.Status.ResolvedTemplates
+	if resolved == nil {
+		return lotus
+	}
+	lotus = lotus.DeepCopy()
+	merge := func(template *lotusv1beta1.LotusPodSpec, containers *[]corev1.Container, volumes *[]corev1.Volume) {
+		if template == nil {
+			return
+		}
+		*containers = append(template.Containers, *containers...)
+		*volumes = append(template.Volumes, *volumes...)
+	}
+	if p := lotus.Spec.Preparer; p != nil {
+		merge(resolved.Preparer, &p.Containers, &p.Volumes)
+	}
+	if w := lotus.Spec.Worker; w != nil {
+		merge(resolved.Worker, &w.Containers, &w.Volumes)
+	}
+	if cl := lotus.Spec.Cleaner; cl != nil {
+		merge(resolved.Cleaner, &cl.Containers, &cl.Volumes)
+	}
+	return lotus
+}
+
+// withCommon returns copies of the given containers and volumes of a stage
+// merged with spec.common and the environment variables identifying the test.
+// The env vars, volumes and volume mounts specified by the stage itself
+// take precedence over the common ones with the same name or mount path.
+func withCommon(lotus *lotusv1beta1.Lotus, stage string, containers []corev1.Container, volumes []corev1.Volume) ([]corev1.Container, []corev1.Volume) {
+	common := lotus.Spec.Common
+	if common == nil {
+		common = &lotusv1beta1.LotusSpecCommon{}
+	}
+	env := []corev1.EnvVar{
+		corev1.EnvVar{
+			Name:  testIDEnv,
+			Value: testID(lotus),
+		},
+		corev1.EnvVar{
+			Name:  nameEnv,
+			Value: lotus.Name,
+		},
+		corev1.EnvVar{
+			Name:  stageEnv,
+			Value: stage,
+		},
+		corev1.EnvVar{
+			Name:  namespaceEnv,
+			Value: lotus.Namespace,
+		},
+	}
+	env = append(env, common.Env...)
+
+	copies := make([]corev1.Container, 0, len(containers))
+	for i := range containers {
+		container := containers[i].DeepCopy()
+		container.Env = mergeEnv(env, container.Env)
+		container.EnvFrom = append(append([]corev1.EnvFromSource{}, common.EnvFrom...), container.EnvFrom...)
+		container.VolumeMounts = mergeVolumeMounts(common.VolumeMounts, container.VolumeMounts)
+		copies = append(copies, *container)
+	}
+	return copies, mergeVolumes(common.Volumes, volumes)
+}
+
+func mergeEnv(base, overrides []corev1.EnvVar) []corev1.EnvVar {
+	names := make(map[string]struct{}, len(overrides))
+	for _, e := range overrides {
+		names[e.Name] = struct{}{}
+	}
+	merged := make([]corev1.EnvVar, 0, len(base)+len(overrides))
+	for _, e := range base {
+		if _, ok := names[e.Name]; !ok {
+			merged = append(merged, e)
+		}
+	}
+	return append(merged, overrides...)
+}
+
+func mergeVolumeMounts(base, overrides []corev1.VolumeMount) []corev1.VolumeMount {
+	paths := make(map[string]struct{}, len(overrides))
+	for _, m := range overrides {
+		paths[m.MountPath] = struct{}{}
+	}
+	merged := make([]corev1.VolumeMount, 0, len(base)+len(overrides))
+	for _, m := range base {
+		if _, ok := paths[m.MountPath]; !ok {
+			merged = append(merged, m)
+		}
+	}
+	return append(merged, overrides...)
+}
+
+func mergeVolumes(base, overrides []corev1.Volume) []corev1.Volume {
+	names := make(map[string]struct{}, len(overrides))
+	for _, v := range overrides {
+		names[v.Name] = struct{}{}
+	}
+	merged := make([]corev1.Volume, 0, len(base)+len(overrides))
+	for _, v := range base {
+		if _, ok := names[v.Name]; !ok {
+			merged = append(merged, v)
+		}
+	}
+	return append(merged, overrides...)
+}
@@ -0,0 +1,128 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package resource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	lotusv1beta1 "github.com/lotusload/lotus/pkg/app/lotus/apis/lotus/v1beta1"
+)
+
+func TestWithCommon(t *testing.T) {
+	lotus := &lotusv1beta1.Lotus{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "load",
+			UID:       "a5f3c2d1",
+		},
+		Spec: lotusv1beta1.LotusSpec{
+			Common: &lotusv1beta1.LotusSpecCommon{
+				Env: []corev1.EnvVar{
+					corev1.EnvVar{Name: "TARGET", Value: "helloworld:8080"},
+					corev1.EnvVar{Name: "LOG_LEVEL", Value: "info"},
+				},
+				EnvFrom: []corev1.EnvFromSource{
+					corev1.EnvFromSource{
+						SecretRef: &corev1.SecretEnvSource{
+							LocalObjectReference: corev1.LocalObjectReference{Name: "credentials"},
+						},
+					},
+				},
+				Volumes: []corev1.Volume{
+					corev1.Volume{Name: "data"},
+					corev1.Volume{Name: "certs"},
+				},
+				VolumeMounts: []corev1.VolumeMount{
+					corev1.VolumeMount{Name: "data", MountPath: "/etc/data"},
+					corev1.VolumeMount{Name: "certs", MountPath: "/etc/certs"},
+				},
+			},
+		},
+	}
+	containers := []corev1.Container{
+		corev1.Container{
+			Name: "cleaner",
+			Env: []corev1.EnvVar{
+				corev1.EnvVar{Name: "LOG_LEVEL", Value: "debug"},
+			},
+			VolumeMounts: []corev1.VolumeMount{
+				corev1.VolumeMount{Name: "own-certs", MountPath: "/etc/certs"},
+			},
+		},
+	}
+	volumes := []corev1.Volume{
+		corev1.Volume{Name: "data", VolumeSource: corev1.VolumeSource{EmptyDir: &corev1.EmptyDirVolumeSource{}}},
+		corev1.Volume{Name: "own-certs"},
+	}
+
+	merged, mergedVolumes := withCommon(lotus, string(JobCleaner), containers, volumes)
+	assert.Equal(t, []corev1.EnvVar{
+		corev1.EnvVar{Name: testIDEnv, Value: "a5f3c2d1"},
+		corev1.EnvVar{Name: nameEnv, Value: "test"},
+		corev1.EnvVar{Name: stageEnv, Value: "cleaner"},
+		corev1.EnvVar{Name: namespaceEnv, Value: "load"},
+		corev1.EnvVar{Name: "TARGET", Value: "helloworld:8080"},
+		corev1.EnvVar{Name: "LOG_LEVEL", Value: "debug"},
+	}, merged[0].Env)
+	assert.Equal(t, lotus.Spec.Common.EnvFrom, merged[0].EnvFrom)
+	assert.Equal(t, []corev1.VolumeMount{
+		corev1.VolumeMount{Name: "data", MountPath: "/etc/data"},
+		corev1.VolumeMount{Name: "own-certs", MountPath: "/etc/certs"},
+	}, merged[0].VolumeMounts)
+	assert.Equal(t, []corev1.Volume{
+		corev1.Volume{Name: "certs"},
+		volumes[0],
+		volumes[1],
+	}, mergedVolumes)
+	// The original containers must not be modified.
+	assert.Equal(t, 1, len(containers[0].Env))
+}
+
+func TestWithResolvedTemplates(t *testing.T) {
+	lotus := &lotusv1beta1.Lotus{
+		Spec: lotusv1beta1.LotusSpec{
+			Worker: &lotusv1beta1.LotusSpecWorker{
+				TemplateRef: &lotusv1beta1.LotusTemplateRef{Kind: lotusv1beta1.LotusTemplatePodTemplate, Name: "worker"},
+				Containers:  []corev1.Container{corev1.Container{Name: "sidecar"}},
+			},
+		},
+	}
+	assert.True(t, lotus == WithResolvedTemplates(lotus))
+
+	lotus.Status.ResolvedTemplates = &lotusv1beta1.LotusResolvedTemplates{
+		Worker: &lotusv1beta1.LotusPodSpec{
+			Containers: []corev1.Container{corev1.Container{Name: "worker"}},
+			Volumes:    []corev1.Volume{corev1.Volume{Name: "data"}},
+		},
+	}
+	resolved := WithResolvedTemplates(lotus)
+	assert.Equal(t, []corev1.Container{
+		corev1.Container{Name: "worker"},
+		corev1.Container{Name: "sidecar"},
+	}, resolved.Spec.Worker.Containers)
+	assert.Equal(t, []corev1.Volume{corev1.Volume{Name: "data"}}, resolved.Spec.Worker.Volumes)
+	// The spec of the given Lotus must not be modified.
+	assert.Len(t, lotus.Spec.Worker.Containers, 1)
+}
@@ -27,6 +27,7 @@ import (
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/labels"
 
 	lotusv1beta1 "github.com/lotusload/lotus/pkg/app/lotus/apis/lotus/v1beta1"
 	"github.com/lotusload/lotus/pkg/app/lotus/config"
@@ -45,17 +46,25 @@ type ResourceFactory interface {
 	MonitorJobName() string
 	CleanerJobName() string
 	WorkerName() string
+	WorkerReplicas() int32
+	WorkerSelector() string
 	PrometheusName() string
+	HandoffName() string
+	MonitorAddress() string
 
 	NewPreparerJob() (*batchv1.Job, error)
 	NewCleanerJob() (*batchv1.Job, error)
 	NewMonitorJob() (*batchv1.Job, error)
 	NewMonitorConfigMap() (*corev1.ConfigMap, error)
+	NewMonitorSecret(token []byte) (*corev1.Secret, error)
+	NewMonitorService() (*corev1.Service, error)
 	NewWorkerDeployment() (*appsv1.Deployment, error)
+	NewWorkerJob() (*batchv1.Job, error)
 	NewWorkerService() (*corev1.Service, error)
 	NewPrometheusPod(serviceAccountName, release string) (*corev1.Pod, error)
 	NewPrometheusService() (*corev1.Service, error)
 	NewPrometheusConfigMap() (*corev1.ConfigMap, error)
+	NewHandoffSecret(data map[string][]byte) (*corev1.Secret, error)
 }
 
 type resourceFactory struct {
@@ -65,7 +74,7 @@ type resourceFactory struct {
 
 func NewFactory(lotus *lotusv1beta1.Lotus, configFile string) ResourceFactory {
 	return &resourceFactory{
-		lotus:      lotus,
+		lotus:      WithResolvedTemplates(lotus),
 		configFile: configFile,
 	}
 }
@@ -86,28 +95,51 @@ func (rf *resourceFactory) WorkerName() string {
 	return workerName(rf.lotus.Name)
 }
 
+func (rf *resourceFactory) WorkerReplicas() int32 {
+	return workerReplicas(rf.lotus)
+}
+
+func (rf *resourceFactory) WorkerSelector() string {
+	return labels.SelectorFromSet(workerLabels(rf.lotus.Name)).String()
+}
+
+func (rf *resourceFactory) HandoffName() string {
+	return handoffName(rf.lotus.Name)
+}
+
 func (rf *resourceFactory) PrometheusName() string {
 	return prometheusName(rf.lotus.Name)
 }
 
+func (rf *resourceFactory) MonitorAddress() string {
+	return monitorAddress(rf.lotus)
+}
+
 func (rf *resourceFactory) NewPreparerJob() (*batchv1.Job, error) {
+	containers, volumes := withCommon(rf.lotus, string(JobPreparer), rf.lotus.Spec.Preparer.Containers, rf.lotus.Spec.Preparer.Volumes)
 	return newJob(
 		rf.lotus,
-		rf.lotus.Spec.Preparer.Containers,
-		rf.lotus.Spec.Preparer.Volumes,
+		withHandoffOutput(containers),
+		volumes,
 		JobPreparer,
 	), nil
 }
 
 func (rf *resourceFactory) NewCleanerJob() (*batchv1.Job, error) {
+	containers, volumes := withCommon(rf.lotus, string(JobCleaner), rf.lotus.Spec.Cleaner.Containers, rf.lotus.Spec.Cleaner.Volumes)
+	containers, volumes = withHandoff(rf.lotus, containers, volumes)
 	return newJob(
 		rf.lotus,
-		rf.lotus.Spec.Cleaner.Containers,
-		rf.lotus.Spec.Cleaner.Volumes,
+		containers,
+		volumes,
 		JobCleaner,
 	), nil
 }
 
+func (rf *resourceFactory) NewHandoffSecret(data map[string][]byte) (*corev1.Secret, error) {
+	return newHandoffSecret(rf.lotus, data), nil
+}
+
 func (rf *resourceFactory) NewMonitorJob() (*batchv1.Job, error) {
 	cfg, err := config.FromFile(rf.configFile)
 	if err != nil {
@@ -128,8 +160,20 @@ func (rf *resourceFactory) NewMonitorConfigMap() (*corev1.ConfigMap, error) {
 	return newMonitorConfigMap(rf.lotus, data), nil
 }
 
+func (rf *resourceFactory) NewMonitorSecret(token []byte) (*corev1.Secret, error) {
+	return newMonitorSecret(rf.lotus, token), nil
+}
+
+func (rf *resourceFactory) NewMonitorService() (*corev1.Service, error) {
+	return newMonitorService(rf.lotus), nil
+}
+
 func (rf *resourceFactory) NewWorkerDeployment() (*appsv1.Deployment, error) {
-	return newWorkerDeployment(rf.lotus), nil
+	return newWorkerDeployment(rf.lotus)
+}
+
+func (rf *resourceFactory) NewWorkerJob() (*batchv1.Job, error) {
+	return newWorkerJob(rf.lotus), nil
 }
 
 func (rf *resourceFactory) NewWorkerService() (*corev1.Service, error) {
@@ -149,26 +193,41 @@ func (rf *resourceFactory) NewPrometheusService() (*corev1.Service, error) {
 }
 
 func (rf *resourceFactory) NewPrometheusConfigMap() (*corev1.ConfigMap, error) {
-	cfg, err := config.FromFile(rf.configFile)
+	cfg, err := buildLotusConfig(rf.configFile, rf.lotus)
 	if err != nil {
 		return nil, err
 	}
 	target := workerName(rf.lotus.Name)
-	return newPrometheusConfigMap(rf.lotus, target, cfg.LotusChecks())
+	return newPrometheusConfigMap(rf.lotus, target, cfg)
 }
 
 func buildLotusConfig(configFile string, lotus *lotusv1beta1.Lotus) (*config.Config, error) {
-	cfg, err := config.FromFile(configFile)
+	cfg, err := config.FromFileForTest(configFile, testID(lotus), lotus.Spec.FailureCriteria)
 	if err != nil {
 		return nil, err
 	}
 	cfg.DataSources = append(cfg.DataSources, clientPrometheusDataSource(lotus))
-	cfg.AddChecks(lotus.Spec.Checks...)
+	if err := cfg.AddChecks(testID(lotus), lotus.Spec.Checks...); err != nil {
+		return nil, err
+	}
 	for i := range cfg.Checks {
 		if cfg.Checks[i].DataSource == "" {
 			cfg.Checks[i].DataSource = localPrometheusDataSourceName
 		}
 	}
+	cfg.AddAssertions(lotus.Spec.Assertions...)
+	for i := range cfg.Assertions {
+		if cfg.Assertions[i].DataSource == "" {
+			cfg.Assertions[i].DataSource = localPrometheusDataSourceName
+		}
+	}
+	cfg.SetStopConditions(lotus.Spec.StopConditions)
+	cfg.AddSummaryMetrics(lotus.Spec.Summary)
+	for _, sc := range cfg.GetStopConditions().GetConditions() {
+		if sc.DataSource == "" {
+			sc.DataSource = localPrometheusDataSourceName
+		}
+	}
 	return cfg, nil
 }
 
@@ -0,0 +1,142 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package resource
+
+import (
+	"bufio"
+	"fmt"
+	"regexp"
+	"strings"
+
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	lotusv1beta1 "github.com/lotusload/lotus/pkg/app/lotus/apis/lotus/v1beta1"
+)
+
+const (
+	// HandoffOutputPath is the file where the preparer containers write
+	// the data to hand off to the workers and the cleaner as KEY=VALUE lines.
+	// It is used as the termination message path of the preparer containers,
+	// so the total size is limited to 4KB per container.
+	HandoffOutputPath = "/var/lotus/output"
+	// HandoffMountPath is the directory where the handed off data is mounted
+	// in the worker and cleaner containers, one file per key.
+	HandoffMountPath = "/var/lotus/handoff"
+
+	handoffVolumeName = "lotus-handoff"
+)
+
+// handoffKeyRegex accepts the keys which are valid names of both
+// environment variables and files, e.g. TENANT_ID.
+var handoffKeyRegex = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+func handoffName(lotusName string) string {
+	return fmt.Sprintf("%s-handoff", lotusName)
+}
+
+func newHandoffSecret(lotus *lotusv1beta1.Lotus, data map[string][]byte) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            handoffName(lotus.Name),
+			Namespace:       lotus.Namespace,
+			OwnerReferences: ownerReferences(lotus),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: data,
+	}
+}
+
+// withHandoffOutput returns copies of the given preparer containers
+// whose termination message is read from HandoffOutputPath.
+func withHandoffOutput(containers []corev1.Container) []corev1.Container {
+	copies := make([]corev1.Container, 0, len(containers))
+	for i := range containers {
+		container := containers[i].DeepCopy()
+		if container.TerminationMessagePath == "" || container.TerminationMessagePath == corev1.TerminationMessagePathDefault {
+			container.TerminationMessagePath = HandoffOutputPath
+		}
+		copies = append(copies, *container)
+	}
+	return copies
+}
+
+// withHandoff returns copies of the given containers and volumes with the data
+// handed off by the preparer injected as environment variables and mounted as files.
+// They are returned as is if the Lotus has no preparer.
+func withHandoff(lotus *lotusv1beta1.Lotus, containers []corev1.Container, volumes []corev1.Volume) ([]corev1.Container, []corev1.Volume) {
+	if lotus.Spec.Preparer == nil {
+		return containers, volumes
+	}
+	optional := true
+	name := handoffName(lotus.Name)
+	copies := make([]corev1.Container, 0, len(containers))
+	for i := range containers {
+		container := containers[i].DeepCopy()
+		container.EnvFrom = append(container.EnvFrom, corev1.EnvFromSource{
+			SecretRef: &corev1.SecretEnvSource{
+				LocalObjectReference: corev1.LocalObjectReference{Name: name},
+				Optional:             &optional,
+			},
+		})
+		container.VolumeMounts = append(container.VolumeMounts, corev1.VolumeMount{
+			Name:      handoffVolumeName,
+			MountPath: HandoffMountPath,
+			ReadOnly:  true,
+		})
+		copies = append(copies, *container)
+	}
+	vs := make([]corev1.Volume, 0, len(volumes)+1)
+	vs = append(vs, volumes...)
+	vs = append(vs, corev1.Volume{
+		Name: handoffVolumeName,
+		VolumeSource: corev1.VolumeSource{
+			Secret: &corev1.SecretVolumeSource{
+				SecretName: name,
+				Optional:   &optional,
+			},
+		},
+	})
+	return copies, vs
+}
+
+// ParseHandoff parses the KEY=VALUE lines written by a preparer container.
+// Empty lines and lines starting with # are ignored.
+func ParseHandoff(output string) (map[string][]byte, error) {
+	data := make(map[string][]byte)
+	scanner := bufio.NewScanner(strings.NewReader(output))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, "=", 2)
+		if len(parts) != 2 {
+			return nil, fmt.Errorf("invalid handoff line: %q", line)
+		}
+		key := strings.TrimSpace(parts[0])
+		if !handoffKeyRegex.MatchString(key) {
+			return nil, fmt.Errorf("invalid handoff key: %q", key)
+		}
+		data[key] = []byte(parts[1])
+	}
+	return data, scanner.Err()
+}
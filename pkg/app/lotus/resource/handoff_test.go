@@ -0,0 +1,77 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package resource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	lotusv1beta1 "github.com/lotusload/lotus/pkg/app/lotus/apis/lotus/v1beta1"
+)
+
+func TestParseHandoff(t *testing.T) {
+	data, err := ParseHandoff("# fixtures\nUSER_ID=1234\n\nTOKEN=a=b\n")
+	require.NoError(t, err)
+	assert.Equal(t, map[string][]byte{
+		"USER_ID": []byte("1234"),
+		"TOKEN":   []byte("a=b"),
+	}, data)
+
+	_, err = ParseHandoff("USER_ID")
+	assert.Error(t, err)
+
+	_, err = ParseHandoff("USER ID=1234")
+	assert.Error(t, err)
+
+	_, err = ParseHandoff("tenant.id=1234")
+	assert.Error(t, err)
+
+	_, err = ParseHandoff("1ST_USER=1234")
+	assert.Error(t, err)
+}
+
+func TestWithHandoff(t *testing.T) {
+	lotus := &lotusv1beta1.Lotus{
+		ObjectMeta: metav1.ObjectMeta{Name: "test"},
+		Spec: lotusv1beta1.LotusSpec{
+			Worker: &lotusv1beta1.LotusSpecWorker{
+				Containers: []corev1.Container{{Name: "worker"}},
+			},
+		},
+	}
+	containers, volumes := withHandoff(lotus, lotus.Spec.Worker.Containers, nil)
+	assert.Empty(t, containers[0].EnvFrom)
+	assert.Empty(t, volumes)
+
+	lotus.Spec.Preparer = &lotusv1beta1.LotusSpecPreparer{}
+	containers, volumes = withHandoff(lotus, lotus.Spec.Worker.Containers, nil)
+	require.Len(t, containers[0].EnvFrom, 1)
+	assert.Equal(t, "test-handoff", containers[0].EnvFrom[0].SecretRef.Name)
+	assert.Equal(t, HandoffMountPath, containers[0].VolumeMounts[0].MountPath)
+	require.Len(t, volumes, 1)
+	assert.Equal(t, "test-handoff", volumes[0].Secret.SecretName)
+	// The spec of the Lotus must not be modified.
+	assert.Empty(t, lotus.Spec.Worker.Containers[0].EnvFrom)
+}
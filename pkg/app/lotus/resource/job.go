@@ -27,6 +27,7 @@ import (
 	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/util/intstr"
 
 	lotusv1beta1 "github.com/lotusload/lotus/pkg/app/lotus/apis/lotus/v1beta1"
 	"github.com/lotusload/lotus/pkg/app/lotus/config"
@@ -40,13 +41,30 @@ const (
 	JobCleaner          = "cleaner"
 )
 
+const (
+	// TestedImageAnnotation is the image tested by the Lotus,
+	// which is shown in the result.
+	TestedImageAnnotation = "lotus.lotusload.com/image"
+	// MonitorStopTokenKey is the key of the monitor Secret holding
+	// the bearer token required by the stop requests.
+	MonitorStopTokenKey = "stop-token"
+
+	monitorPort = 9091
+)
+
 func newMonitorJob(lotus *lotusv1beta1.Lotus, cfg *config.Config) *batchv1.Job {
 	args := []string{
 		"monitor",
-		fmt.Sprintf("--test-id=%s", lotus.Name),
+		fmt.Sprintf("--test-id=%s", testID(lotus)),
+		fmt.Sprintf("--lotus-name=%s", lotus.Name),
 		fmt.Sprintf("--run-time=%s", lotus.Spec.Worker.RunTime),
 		"--config-file=/etc/monitor/config/config.yaml",
 		fmt.Sprintf("--collect-summary-datasource=%s", localPrometheusDataSourceName),
+		fmt.Sprintf("--port=%d", monitorPort),
+		fmt.Sprintf("--stop-token-file=/etc/monitor/secret/%s", MonitorStopTokenKey),
+	}
+	if image := lotus.Annotations[TestedImageAnnotation]; image != "" {
+		args = append(args, fmt.Sprintf("--tested-image=%s", image))
 	}
 	if s := lotus.Spec.CheckIntervalSeconds; s != nil {
 		d := time.Duration(*s) * time.Second
@@ -56,17 +74,32 @@ func newMonitorJob(lotus *lotusv1beta1.Lotus, cfg *config.Config) *batchv1.Job {
 		d := time.Duration(*s) * time.Second
 		args = append(args, fmt.Sprintf("--check-initial-delay=%s", d.String()))
 	}
+	if s := lotus.Spec.InterimReportIntervalSeconds; s != nil {
+		d := time.Duration(*s) * time.Second
+		args = append(args, fmt.Sprintf("--interim-report-interval=%s", d.String()))
+	}
 	container := corev1.Container{
 		Name:  "monitor",
 		Image: lotusImage,
 		Args:  args,
 		Env:   []corev1.EnvVar{},
+		Ports: []corev1.ContainerPort{
+			corev1.ContainerPort{
+				Name:          "http",
+				ContainerPort: monitorPort,
+			},
+		},
 		VolumeMounts: []corev1.VolumeMount{
 			corev1.VolumeMount{
 				Name:      "config",
 				ReadOnly:  true,
 				MountPath: "/etc/monitor/config",
 			},
+			corev1.VolumeMount{
+				Name:      "secret",
+				ReadOnly:  true,
+				MountPath: "/etc/monitor/secret",
+			},
 		},
 	}
 	volumes := []corev1.Volume{
@@ -80,6 +113,14 @@ func newMonitorJob(lotus *lotusv1beta1.Lotus, cfg *config.Config) *batchv1.Job {
 				},
 			},
 		},
+		corev1.Volume{
+			Name: "secret",
+			VolumeSource: corev1.VolumeSource{
+				Secret: &corev1.SecretVolumeSource{
+					SecretName: jobName(lotus.Name, JobMonitor),
+				},
+			},
+		},
 	}
 	for _, receiver := range cfg.Receivers {
 		gcsReceiver, ok := receiver.Type.(*config.Receiver_Gcs)
@@ -130,6 +171,49 @@ func newMonitorConfigMap(lotus *lotusv1beta1.Lotus, config []byte) *corev1.Confi
 	}
 }
 
+// newMonitorSecret returns the Secret sharing the stop token between the controller and the monitor.
+func newMonitorSecret(lotus *lotusv1beta1.Lotus, token []byte) *corev1.Secret {
+	return &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            jobName(lotus.Name, JobMonitor),
+			Namespace:       lotus.Namespace,
+			OwnerReferences: ownerReferences(lotus),
+		},
+		Type: corev1.SecretTypeOpaque,
+		Data: map[string][]byte{
+			MonitorStopTokenKey: token,
+		},
+	}
+}
+
+func newMonitorService(lotus *lotusv1beta1.Lotus) *corev1.Service {
+	return &corev1.Service{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            jobName(lotus.Name, JobMonitor),
+			Namespace:       lotus.Namespace,
+			OwnerReferences: ownerReferences(lotus),
+		},
+		Spec: corev1.ServiceSpec{
+			Selector: jobLabels(lotus.Name, JobMonitor),
+			Ports: []corev1.ServicePort{
+				corev1.ServicePort{
+					Name:       "http",
+					TargetPort: intstr.FromInt(monitorPort),
+					Port:       int32(monitorPort),
+				},
+			},
+		},
+	}
+}
+
+func monitorAddress(lotus *lotusv1beta1.Lotus) string {
+	return fmt.Sprintf("http://%s.%s.svc.cluster.local:%d",
+		jobName(lotus.Name, JobMonitor),
+		lotus.Namespace,
+		monitorPort,
+	)
+}
+
 func newJob(lotus *lotusv1beta1.Lotus, containers []corev1.Container, volumes []corev1.Volume, jt JobType) *batchv1.Job {
 	var backoffLimit int32
 	labels := jobLabels(lotus.Name, jt)
@@ -22,6 +22,7 @@ package resource
 
 import (
 	"fmt"
+	"time"
 
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
@@ -29,6 +30,7 @@ import (
 
 	lotusv1beta1 "github.com/lotusload/lotus/pkg/app/lotus/apis/lotus/v1beta1"
 	"github.com/lotusload/lotus/pkg/app/lotus/config"
+	"github.com/lotusload/lotus/pkg/app/lotus/model"
 )
 
 const (
@@ -38,6 +40,10 @@ const (
 	prometheusPort                = 9090
 	localPrometheusDataSourceName = "_LocalPrometheus"
 	prometheusBlockDuration       = "1m"
+	annotationExportDuration      = 10 * time.Minute
+
+	// MaxAnnotations is the number of the latest annotations kept in the status of a Lotus.
+	MaxAnnotations = 10
 )
 
 func newPrometheusPod(lotus *lotusv1beta1.Lotus, serviceAccount, release string, cfg *config.Config) (*corev1.Pod, error) {
@@ -95,6 +101,7 @@ func newPrometheusPod(lotus *lotusv1beta1.Lotus, serviceAccount, release string,
 			"--tsdb.path=/var/prometheus",
 			fmt.Sprintf("--prometheus.url=http://127.0.0.1:%d", prometheusPort),
 			"--cluster.disable",
+			fmt.Sprintf("--reloader.rule-dir=%s", prometheusConfigDirectory),
 		},
 		Env:   []corev1.EnvVar{},
 		Ports: thanosPorts(),
@@ -159,10 +166,12 @@ func newPrometheusService(lotus *lotusv1beta1.Lotus) *corev1.Service {
 	}
 }
 
-func newPrometheusConfigMap(lotus *lotusv1beta1.Lotus, target string, globalChecks []lotusv1beta1.LotusCheck) (*corev1.ConfigMap, error) {
+func newPrometheusConfigMap(lotus *lotusv1beta1.Lotus, target string, cfg *config.Config) (*corev1.ConfigMap, error) {
 	config, err := renderTemplate(
 		&prometheusConfigParams{
 			Name:        prometheusName(lotus.Name),
+			TestID:      testID(lotus),
+			TestIDLabel: model.TestIDLabel,
 			Namespace:   lotus.Namespace,
 			ServiceName: target,
 			RuleFiles: []string{
@@ -174,10 +183,11 @@ func newPrometheusConfigMap(lotus *lotusv1beta1.Lotus, target string, globalChec
 	if err != nil {
 		return nil, err
 	}
-	globalChecks = append(globalChecks, lotus.Spec.Checks...)
 	rule, err := renderTemplate(
 		&prometheusRuleParams{
-			Alerts: globalChecks,
+			Annotations:     prometheusAnnotations(lotus, target),
+			GRPCFailureRate: failureRate("lotus_grpc_client_completed_rpcs", cfg.FailureCriteria.DashboardGRPCFailureMatchers()),
+			HTTPFailureRate: failureRate("lotus_http_client_completed_count", cfg.FailureCriteria.HTTPFailureMatchers()),
 		},
 		prometheusRuleTemplate,
 	)
@@ -197,6 +207,34 @@ func newPrometheusConfigMap(lotus *lotusv1beta1.Lotus, target string, globalChec
 	}, nil
 }
 
+// prometheusAnnotations returns the latest MaxAnnotations annotations of the given Lotus to export.
+// Each of them is exported for annotationExportDuration only, which is enough for the dashboards
+// to show it at the time of its value, so that the past annotations are not evaluated forever.
+func prometheusAnnotations(lotus *lotusv1beta1.Lotus, target string) []prometheusAnnotation {
+	latest := lotus.Status.Annotations
+	if len(latest) > MaxAnnotations {
+		latest = latest[len(latest)-MaxAnnotations:]
+	}
+	annotations := make([]prometheusAnnotation, 0, len(latest))
+	for _, a := range latest {
+		annotations = append(annotations, prometheusAnnotation{
+			Job:       target,
+			TestID:    testID(lotus),
+			Timestamp: a.Time.UnixNano() / 1e6,
+			Expires:   a.Time.Add(annotationExportDuration).Unix(),
+			Text:      a.Text,
+		})
+	}
+	return annotations
+}
+
+// failureRate returns the rate of the failures of the given counter selected by the disjoint matchers.
+func failureRate(metric string, matchers []string) string {
+	return config.UnionOf(matchers, func(matcher string) string {
+		return fmt.Sprintf("rate(%s{%s}[1m])", metric, matcher)
+	})
+}
+
 func prometheusName(lotusName string) string {
 	return fmt.Sprintf("%s-prometheus", lotusName)
 }
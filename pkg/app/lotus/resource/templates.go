@@ -23,8 +23,6 @@ package resource
 import (
 	"bytes"
 	"text/template"
-
-	"github.com/lotusload/lotus/pkg/app/lotus/apis/lotus/v1beta1"
 )
 
 func renderTemplate(params interface{}, tpl string) ([]byte, error) {
@@ -42,6 +40,8 @@ func renderTemplate(params interface{}, tpl string) ([]byte, error) {
 
 type prometheusConfigParams struct {
 	Name        string
+	TestID      string
+	TestIDLabel string
 	Namespace   string
 	ServiceName string
 	RuleFiles   []string
@@ -95,6 +95,9 @@ scrape_configs:
     target_label: job
     replacement: ${1}
     action: replace
+  - target_label: {{ .TestIDLabel }}
+    replacement: {{ .TestID }}
+    action: replace
 {{- if gt (len .RuleFiles) 0 }}
 rule_files:
 {{- range .RuleFiles }}
@@ -104,40 +107,57 @@ rule_files:
 `
 
 type prometheusRuleParams struct {
-	Alerts []v1beta1.LotusCheck
+	Annotations []prometheusAnnotation
+	// The rates of the failed RPCs and HTTP requests according to the failure criteria.
+	GRPCFailureRate string
+	HTTPFailureRate string
+}
+
+// prometheusAnnotation is exported as a lotus_annotation series whose value is
+// the time of the annotation in milliseconds, so that it can be shown on
+// the Grafana dashboards. The series ends at Expires in seconds.
+type prometheusAnnotation struct {
+	Job       string
+	TestID    string
+	Timestamp int64
+	Expires   int64
+	Text      string
 }
 
 const prometheusRuleTemplate = `
 groups:
 - name: lotus
   rules:
-{{- range .Alerts }}
-  - alert: {{ .Name }}
-    expr: {{ .Expr }}
-    for: {{ .For }}
+{{- range .Annotations }}
+  - record: lotus_annotation
+    expr: vector({{ .Timestamp }}) and on() (vector(time()) < {{ .Expires }})
+    labels:
+      job: {{ .Job }}
+      lotus_test_id: {{ .TestID }}
+      text: {{ printf "%q" .Text }}
 {{- end }}
   - record: lotus_virtual_user_failure_percentage
-    expr: 100 * sum by (job) (lotus_virtual_user_count{virtual_user_status="failed"}) / sum by (job) (lotus_virtual_user_count{virtual_user_status="started"})
+    expr: 100 * sum by (job, lotus_test_id) (lotus_virtual_user_count{virtual_user_status="failed"}) / sum by (job, lotus_test_id) (lotus_virtual_user_count{virtual_user_status="started"})
   - record: lotus_grpc_client_completed_rpcs_per_second:method
-    expr: sum by (job, grpc_client_method) (rate(lotus_grpc_client_completed_rpcs[1m]))
+    expr: sum by (job, lotus_test_id, grpc_client_method) (rate(lotus_grpc_client_completed_rpcs[1m]))
   - record: lotus_grpc_client_completed_rpcs_per_second:status
-    expr: sum by (job, grpc_client_status) (rate(lotus_grpc_client_completed_rpcs[1m]))
+    expr: sum by (job, lotus_test_id, grpc_client_status) (rate(lotus_grpc_client_completed_rpcs[1m]))
   - record: lotus_grpc_client_completed_rpcs_failure_percentage:method
-    expr: 100 * sum by (job, grpc_client_method) (rate(lotus_grpc_client_completed_rpcs{grpc_client_status!~"OK|NOT_FOUND|ALREADY_EXISTS"}[1m])) / sum by (job, grpc_client_method) (rate(lotus_grpc_client_completed_rpcs[1m]))
+    expr: 100 * sum by (job, lotus_test_id, grpc_client_method) ({{ .GRPCFailureRate }}) / sum by (job, lotus_test_id, grpc_client_method) (rate(lotus_grpc_client_completed_rpcs[1m]))
   - record: lotus_grpc_client_roundtrip_latency:method
-    expr: sum by (job, grpc_client_method) (rate(lotus_grpc_client_roundtrip_latency_sum[1m])) / sum by (job, grpc_client_method) (rate(lotus_grpc_client_roundtrip_latency_count[1m]))
+    expr: sum by (job, lotus_test_id, grpc_client_method) (rate(lotus_grpc_client_roundtrip_latency_sum[1m])) / sum by (job, lotus_test_id, grpc_client_method) (rate(lotus_grpc_client_roundtrip_latency_count[1m]))
   - record: lotus_grpc_client_sent_bytes_per_rpc:method
-    expr: sum by (job, grpc_client_method) (rate(lotus_grpc_client_sent_bytes_per_rpc_sum[1m])) / sum by (job, grpc_client_method) (rate(lotus_grpc_client_sent_bytes_per_rpc_count[1m]))
+    expr: sum by (job, lotus_test_id, grpc_client_method) (rate(lotus_grpc_client_sent_bytes_per_rpc_sum[1m])) / sum by (job, lotus_test_id, grpc_client_method) (rate(lotus_grpc_client_sent_bytes_per_rpc_count[1m]))
   - record: lotus_grpc_client_received_bytes_per_rpc:method
-    expr: sum by (job, grpc_client_method) (rate(lotus_grpc_client_received_bytes_per_rpc_sum[1m])) / sum by (job, grpc_client_method) (rate(lotus_grpc_client_received_bytes_per_rpc_count[1m]))
+    expr: sum by (job, lotus_test_id, grpc_client_method) (rate(lotus_grpc_client_received_bytes_per_rpc_sum[1m])) / sum by (job, lotus_test_id, grpc_client_method) (rate(lotus_grpc_client_received_bytes_per_rpc_count[1m]))
   - record: lotus_http_client_completed_requests_per_second:host:route:method
-    expr: sum by (job, http_client_host, http_client_route, http_client_method) (rate(lotus_http_client_completed_count[1m]))
+    expr: sum by (job, lotus_test_id, http_client_host, http_client_route, http_client_method) (rate(lotus_http_client_completed_count[1m]))
   - record: lotus_http_client_completed_requests_5xx_percentage:host:route:method
-    expr: 100 * sum by (job, http_client_host, http_client_route, http_client_method) (rate(lotus_http_client_completed_count{http_client_status=~"5.."}[1m])) / sum by (job, http_client_host, http_client_route, http_client_method) (rate(lotus_http_client_completed_count[1m]))
+    expr: 100 * sum by (job, lotus_test_id, http_client_host, http_client_route, http_client_method) ({{ .HTTPFailureRate }}) / sum by (job, lotus_test_id, http_client_host, http_client_route, http_client_method) (rate(lotus_http_client_completed_count[1m]))
   - record: lotus_http_client_roundtrip_latency:host:route:method
-    expr: sum by (job, http_client_host, http_client_route, http_client_method) (rate(lotus_http_client_roundtrip_latency_sum[1m])) / sum by (job, http_client_host, http_client_route, http_client_method) (rate(lotus_http_client_roundtrip_latency_count[1m]))
+    expr: sum by (job, lotus_test_id, http_client_host, http_client_route, http_client_method) (rate(lotus_http_client_roundtrip_latency_sum[1m])) / sum by (job, lotus_test_id, http_client_host, http_client_route, http_client_method) (rate(lotus_http_client_roundtrip_latency_count[1m]))
   - record: lotus_http_client_sent_bytes:host:route:method
-    expr: sum by (job, http_client_host, http_client_route, http_client_method) (rate(lotus_http_client_sent_bytes_sum[1m])) / sum by (job, http_client_host, http_client_route, http_client_method) (rate(lotus_http_client_sent_bytes_count[1m]))
+    expr: sum by (job, lotus_test_id, http_client_host, http_client_route, http_client_method) (rate(lotus_http_client_sent_bytes_sum[1m])) / sum by (job, lotus_test_id, http_client_host, http_client_route, http_client_method) (rate(lotus_http_client_sent_bytes_count[1m]))
   - record: lotus_http_client_received_bytes:host:route:method
-    expr: sum by (job, http_client_host, http_client_route, http_client_method) (rate(lotus_http_client_received_bytes_sum[1m])) / sum by (job, http_client_host, http_client_route, http_client_method) (rate(lotus_http_client_received_bytes_count[1m]))
+    expr: sum by (job, lotus_test_id, http_client_host, http_client_route, http_client_method) (rate(lotus_http_client_received_bytes_sum[1m])) / sum by (job, lotus_test_id, http_client_host, http_client_route, http_client_method) (rate(lotus_http_client_received_bytes_count[1m]))
 `
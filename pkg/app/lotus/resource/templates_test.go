@@ -23,8 +23,13 @@ package resource
 import (
 	"fmt"
 	"testing"
+	"time"
 
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	lotusv1beta1 "github.com/lotusload/lotus/pkg/app/lotus/apis/lotus/v1beta1"
 )
 
 func TestRenderTemplate(t *testing.T) {
@@ -40,3 +45,29 @@ func TestRenderTemplate(t *testing.T) {
 	require.NoError(t, err)
 	fmt.Println(string(cfg))
 }
+
+func TestPrometheusAnnotations(t *testing.T) {
+	start := time.Date(2019, 1, 1, 0, 0, 0, 0, time.UTC)
+	lotus := &lotusv1beta1.Lotus{
+		ObjectMeta: metav1.ObjectMeta{UID: "a5f3c2d1"},
+	}
+	for i := 0; i < MaxAnnotations+2; i++ {
+		lotus.Status.Annotations = append(lotus.Status.Annotations, lotusv1beta1.LotusAnnotation{
+			Time: metav1.NewTime(start.Add(time.Duration(i) * time.Minute)),
+			Text: fmt.Sprintf("worker updated: replicas %d -> %d", i, i+1),
+		})
+	}
+	annotations := prometheusAnnotations(lotus, "test-worker")
+	require.Len(t, annotations, MaxAnnotations)
+	assert.Equal(t, prometheusAnnotation{
+		Job:       "test-worker",
+		TestID:    "a5f3c2d1",
+		Timestamp: start.Add(2*time.Minute).UnixNano() / 1e6,
+		Expires:   start.Add(12 * time.Minute).Unix(),
+		Text:      "worker updated: replicas 2 -> 3",
+	}, annotations[0])
+
+	rule, err := renderTemplate(&prometheusRuleParams{Annotations: annotations[:1]}, prometheusRuleTemplate)
+	require.NoError(t, err)
+	assert.Contains(t, string(rule), fmt.Sprintf("expr: vector(%d) and on() (vector(time()) < %d)", annotations[0].Timestamp, annotations[0].Expires))
+}
@@ -21,9 +21,13 @@
 package resource
 
 import (
+	"encoding/json"
 	"fmt"
+	"hash/fnv"
+	"strconv"
 
 	appsv1 "k8s.io/api/apps/v1"
+	batchv1 "k8s.io/api/batch/v1"
 	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/util/intstr"
@@ -31,33 +35,115 @@ import (
 	lotusv1beta1 "github.com/lotusload/lotus/pkg/app/lotus/apis/lotus/v1beta1"
 )
 
-func newWorkerDeployment(lotus *lotusv1beta1.Lotus) *appsv1.Deployment {
+const (
+	// WorkerTemplateHashAnnotation is set on the worker Deployment to detect
+	// the changes of its pod template without being affected by server-side defaults.
+	WorkerTemplateHashAnnotation = "lotus.lotusload.com/worker-template-hash"
+
+	// CompletionIndexAnnotation is set by Kubernetes on the pods of an Indexed Job.
+	CompletionIndexAnnotation = "batch.kubernetes.io/job-completion-index"
+
+	workerIndexEnv = "LOTUS_WORKER_INDEX"
+	workerCountEnv = "LOTUS_WORKER_COUNT"
+)
+
+func newWorkerDeployment(lotus *lotusv1beta1.Lotus) (*appsv1.Deployment, error) {
 	labels := workerLabels(lotus.Name)
+	replicas := workerReplicas(lotus)
+	containers, volumes := workerContainers(lotus)
+	template := corev1.PodTemplateSpec{
+		ObjectMeta: metav1.ObjectMeta{
+			Labels: labels,
+		},
+		Spec: corev1.PodSpec{
+			RestartPolicy: corev1.RestartPolicyAlways,
+			Containers:    containers,
+			Volumes:       volumes,
+		},
+	}
+	hash, err := templateHash(&template)
+	if err != nil {
+		return nil, err
+	}
 	return &appsv1.Deployment{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:            workerName(lotus.Name),
 			Namespace:       lotus.Namespace,
 			OwnerReferences: ownerReferences(lotus),
+			Annotations: map[string]string{
+				WorkerTemplateHashAnnotation: hash,
+			},
 		},
 		Spec: appsv1.DeploymentSpec{
-			Replicas: lotus.Spec.Worker.Replicas,
+			Replicas: &replicas,
 			Selector: &metav1.LabelSelector{
 				MatchLabels: labels,
 			},
+			Template: template,
+		},
+	}, nil
+}
+
+// newWorkerJob returns the Job running all shards to completion.
+// It must be created as an Indexed Job, so that every pod gets a stable
+// LOTUS_WORKER_INDEX in the range [0, LOTUS_WORKER_COUNT) from its completion index.
+func newWorkerJob(lotus *lotusv1beta1.Lotus) *batchv1.Job {
+	var backoffLimit int32
+	labels := workerLabels(lotus.Name)
+	replicas := workerReplicas(lotus)
+	env := []corev1.EnvVar{
+		corev1.EnvVar{
+			Name: workerIndexEnv,
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{
+					FieldPath: fmt.Sprintf("metadata.annotations['%s']", CompletionIndexAnnotation),
+				},
+			},
+		},
+		corev1.EnvVar{
+			Name:  workerCountEnv,
+			Value: strconv.Itoa(int(replicas)),
+		},
+	}
+	base, volumes := workerContainers(lotus)
+	containers := make([]corev1.Container, 0, len(base))
+	for i := range base {
+		container := base[i].DeepCopy()
+		container.Env = append(container.Env, env...)
+		containers = append(containers, *container)
+	}
+	return &batchv1.Job{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:            workerName(lotus.Name),
+			Namespace:       lotus.Namespace,
+			OwnerReferences: ownerReferences(lotus),
+			Labels:          labels,
+		},
+		Spec: batchv1.JobSpec{
+			Parallelism:  &replicas,
+			Completions:  &replicas,
+			BackoffLimit: &backoffLimit,
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels: labels,
 				},
 				Spec: corev1.PodSpec{
-					RestartPolicy: corev1.RestartPolicyAlways,
-					Containers:    lotus.Spec.Worker.Containers,
-					Volumes:       lotus.Spec.Worker.Volumes,
+					RestartPolicy: corev1.RestartPolicyNever,
+					Containers:    containers,
+					Volumes:       volumes,
 				},
 			},
 		},
 	}
 }
 
+// workerContainers returns the containers and volumes of the worker pods
+// merged with the common spec and the data handed off by the preparer.
+func workerContainers(lotus *lotusv1beta1.Lotus) ([]corev1.Container, []corev1.Volume) {
+	containers, volumes := withCommon(lotus, workerStage, lotus.Spec.Worker.Containers, lotus.Spec.Worker.Volumes)
+	return withHandoff(lotus, containers, volumes)
+}
+
 func newWorkerService(lotus *lotusv1beta1.Lotus) *corev1.Service {
 	labels := workerLabels(lotus.Name)
 	metricsPort := *lotus.Spec.Worker.MetricsPort
@@ -85,6 +171,23 @@ func workerName(lotusName string) string {
 	return fmt.Sprintf("%s-worker", lotusName)
 }
 
+func workerReplicas(lotus *lotusv1beta1.Lotus) int32 {
+	if lotus.Spec.Worker.Replicas == nil {
+		return 1
+	}
+	return *lotus.Spec.Worker.Replicas
+}
+
+func templateHash(template *corev1.PodTemplateSpec) (string, error) {
+	data, err := json.Marshal(template)
+	if err != nil {
+		return "", err
+	}
+	hasher := fnv.New32a()
+	hasher.Write(data)
+	return strconv.FormatUint(uint64(hasher.Sum32()), 16), nil
+}
+
 func workerLabels(lotusName string) map[string]string {
 	return map[string]string{
 		"app":   "lotus-worker",
@@ -0,0 +1,79 @@
+// Copyright (c) 2018 Lotus Load
+//
+// Permission is hereby granted, free of charge, to any person obtaining a copy
+// of this software and associated documentation files (the "Software"), to deal
+// in the Software without restriction, including without limitation the rights
+// to use, copy, modify, merge, publish, distribute, sublicense, and/or sell
+// copies of the Software, and to permit persons to whom the Software is
+// furnished to do so, subject to the following conditions:
+//
+// The above copyright notice and this permission notice shall be included in all
+// copies or substantial portions of the Software.
+
+// THE SOFTWARE IS PROVIDED "AS IS", WITHOUT WARRANTY OF ANY KIND, EXPRESS OR
+// IMPLIED, INCLUDING BUT NOT LIMITED TO THE WARRANTIES OF MERCHANTABILITY,
+// FITNESS FOR A PARTICULAR PURPOSE AND NONINFRINGEMENT. IN NO EVENT SHALL THE
+// AUTHORS OR COPYRIGHT HOLDERS BE LIABLE FOR ANY CLAIM, DAMAGES OR OTHER
+// LIABILITY, WHETHER IN AN ACTION OF CONTRACT, TORT OR OTHERWISE, ARISING FROM,
+// OUT OF OR IN CONNECTION WITH THE SOFTWARE OR THE USE OR OTHER DEALINGS IN THE
+// SOFTWARE.
+
+package resource
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+
+	lotusv1beta1 "github.com/lotusload/lotus/pkg/app/lotus/apis/lotus/v1beta1"
+)
+
+func TestNewWorkerJob(t *testing.T) {
+	replicas := int32(3)
+	lotus := &lotusv1beta1.Lotus{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      "test",
+			Namespace: "default",
+			UID:       "a5f3c2d1",
+		},
+		Spec: lotusv1beta1.LotusSpec{
+			Worker: &lotusv1beta1.LotusSpecWorker{
+				Mode:     lotusv1beta1.LotusWorkerJob,
+				Replicas: &replicas,
+				Containers: []corev1.Container{
+					corev1.Container{
+						Name: "worker",
+						Env: []corev1.EnvVar{
+							corev1.EnvVar{Name: "FOO", Value: "bar"},
+						},
+					},
+				},
+			},
+		},
+	}
+	job := newWorkerJob(lotus)
+	assert.Equal(t, "test-worker", job.Name)
+	assert.Equal(t, int32(3), *job.Spec.Parallelism)
+	assert.Equal(t, int32(3), *job.Spec.Completions)
+	assert.Equal(t, "lotus-worker", job.Spec.Template.Labels["app"])
+	assert.Equal(t, []corev1.EnvVar{
+		corev1.EnvVar{Name: testIDEnv, Value: "a5f3c2d1"},
+		corev1.EnvVar{Name: nameEnv, Value: "test"},
+		corev1.EnvVar{Name: stageEnv, Value: workerStage},
+		corev1.EnvVar{Name: namespaceEnv, Value: "default"},
+		corev1.EnvVar{Name: "FOO", Value: "bar"},
+		corev1.EnvVar{
+			Name: workerIndexEnv,
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{
+					FieldPath: "metadata.annotations['batch.kubernetes.io/job-completion-index']",
+				},
+			},
+		},
+		corev1.EnvVar{Name: workerCountEnv, Value: "3"},
+	}, job.Spec.Template.Spec.Containers[0].Env)
+	// The original spec must not be modified.
+	assert.Equal(t, 1, len(lotus.Spec.Worker.Containers[0].Env))
+}